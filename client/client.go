@@ -0,0 +1,184 @@
+// Package client plays doodle against a running server. A Client holds the
+// session of a single player: it creates or joins a game over the REST api and
+// then connects to the game's websocket to draw, guess and follow the game
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const API_V1_PREFIX = "/api/v1"
+const SESSION_COOKIE = "session-token"
+
+// ErrNoGame is returned by calls that need a game before one was created or joined
+var ErrNoGame = errors.New("No game has been created or joined yet")
+
+// StatusError is returned when the server answers a request with anything but a 2xx status
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s returned status %d", e.Method, e.Path, e.StatusCode)
+}
+
+// Client is a single player's session. The session token handed out when the
+// game is created or joined is sent along with every later request, as a bearer
+// token when the server gives one out and as the session cookie otherwise
+type Client struct {
+	// HTTPClient sends the REST requests, it defaults to http.DefaultClient
+	HTTPClient *http.Client
+	baseURL    string
+	gameId     string
+	player     string
+	token      string
+	cookie     *http.Cookie
+}
+
+// New returns a Client for the server at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string) *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// GameId is the id of the game the player created or joined
+func (c *Client) GameId() string {
+	return c.gameId
+}
+
+// Player is the name the player created or joined the game with
+func (c *Client) Player() string {
+	return c.player
+}
+
+// CreateGame creates a new game with the player as its admin and returns its id
+func (c *Client) CreateGame(request CreateGameRequest) (string, error) {
+	response := CreateGameResponse{}
+	if err := c.do(http.MethodPost, "/game", request, &response); err != nil {
+		return "", err
+	}
+	c.gameId, c.player = response.GameId, request.Player
+	if len(response.Token) != 0 {
+		c.token = response.Token
+	}
+	return response.GameId, nil
+}
+
+// JoinGame adds player to the game with gameId
+func (c *Client) JoinGame(gameId, player string) error {
+	response := JoinGameResponse{}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/game/%s", gameId), JoinGameRequest{Player: player}, &response); err != nil {
+		return err
+	}
+	c.gameId, c.player = gameId, player
+	if len(response.Token) != 0 {
+		c.token = response.Token
+	}
+	return nil
+}
+
+// StartGame starts the game, only its admin may do so
+func (c *Client) StartGame() error {
+	return c.gameCall(http.MethodPost, "/start")
+}
+
+// Rematch brings a finished game back to the lobby, only its admin may do so
+func (c *Client) Rematch() error {
+	return c.gameCall(http.MethodPost, "/rematch")
+}
+
+// Leave takes the player out of the game
+func (c *Client) Leave() error {
+	return c.gameCall(http.MethodDelete, "/players/me")
+}
+
+// Kick removes player from the game, only its admin may do so
+func (c *Client) Kick(player string) error {
+	return c.gameCall(http.MethodDelete, fmt.Sprintf("/players/%s", url.PathEscape(player)))
+}
+
+// RefreshSession pushes back the expiry of the player's session. Connecting to
+// the game refreshes it as well, long running clients call this in between
+func (c *Client) RefreshSession() error {
+	if len(c.gameId) == 0 {
+		return ErrNoGame
+	}
+	return c.do(http.MethodPost, fmt.Sprintf("/connect/game/%s/session", c.gameId), nil, nil)
+}
+
+func (c *Client) gameCall(method, path string) error {
+	if len(c.gameId) == 0 {
+		return ErrNoGame
+	}
+	return c.do(method, fmt.Sprintf("/game/%s%s", c.gameId, path), nil, nil)
+}
+
+// do sends body as json and decodes the response into response, if not nil
+func (c *Client) do(method, path string, body any, response any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.baseURL+API_V1_PREFIX+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(request.Header)
+	resp, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	c.keepCookie(resp)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Method: method, Path: path, StatusCode: resp.StatusCode}
+	}
+	if response == nil {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, response)
+}
+
+// authorize adds the player's session token to header. The session cookie is
+// scoped to the connect endpoints, it is still sent everywhere since the
+// server reads it on every authorized request
+func (c *Client) authorize(header http.Header) {
+	if len(c.token) != 0 {
+		header.Set("Authorization", "Bearer "+c.token)
+		return
+	}
+	if c.cookie != nil {
+		header.Set("Cookie", (&http.Cookie{Name: c.cookie.Name, Value: c.cookie.Value}).String())
+	}
+}
+
+// keepCookie remembers the session cookie the server set, if any
+func (c *Client) keepCookie(resp *http.Response) {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == SESSION_COOKIE {
+			c.cookie = cookie
+		}
+	}
+}
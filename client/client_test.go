@@ -0,0 +1,115 @@
+package client
+
+import (
+	"github.com/anchal00/doodle/internal/parser"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer answers create and start like a server that only hands out session cookies
+func fakeServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/game", func(writer http.ResponseWriter, request *http.Request) {
+		http.SetCookie(writer, &http.Cookie{Name: SESSION_COOKIE, Value: "secret", Path: "/api/v1/connect"})
+		writer.WriteHeader(http.StatusCreated)
+		_, _ = writer.Write([]byte(`{"game_id":"abc123"}`))
+	})
+	mux.HandleFunc("POST /api/v1/game/abc123/start", func(writer http.ResponseWriter, request *http.Request) {
+		cookie, err := request.Cookie(SESSION_COOKIE)
+		if err != nil || cookie.Value != "secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSessionCookieIsSentWithLaterRequests(t *testing.T) {
+	server := fakeServer(t)
+	c := New(server.URL)
+	assert.ErrorIs(t, c.StartGame(), ErrNoGame)
+	gameId, err := c.CreateGame(CreateGameRequest{Player: "alice"})
+	require.Nil(t, err)
+	assert.Equal(t, "abc123", gameId)
+	assert.Equal(t, "alice", c.Player())
+	assert.Nil(t, c.StartGame())
+}
+
+func TestStatusError(t *testing.T) {
+	server := fakeServer(t)
+	c := New(server.URL)
+	err := c.JoinGame("abc123", "bob")
+	statusErr, ok := err.(*StatusError)
+	require.True(t, ok, "Expected a StatusError")
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Empty(t, c.GameId())
+}
+
+func TestConnectionExchangesTypedMessages(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{WEBSOCKET_PROTOCOL}}
+	received := make(chan *parser.Envelope, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		protocols := websocket.Subprotocols(request)
+		if request.URL.Path != "/api/v1/connect/game/abc123" || len(protocols) != 2 || protocols[1] != TOKEN_PROTOCOL_PREFIX+"secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, frame := range []string{
+			`{"version":1,"type":"cheer","seq":1,"payload":{}}`,
+			`{"version":1,"type":"hint","seq":2,"payload":{"hint":"_p__e"}}`,
+		} {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(frame))
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		envelope, _, _ := parser.DecodePlayerMessage(data)
+		received <- envelope
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.gameId, c.token = "abc123", "secret"
+	conn, err := c.Connect()
+	require.Nil(t, err)
+	defer conn.Close()
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	event, err := conn.Receive()
+	require.Nil(t, err)
+	assert.Equal(t, &Event{Type: "cheer", Seq: 1}, event, "Unknown events come without a payload")
+	event, err = conn.WaitFor(MSG_HINT)
+	require.Nil(t, err)
+	assert.Equal(t, &HintEvent{Hint: "_p__e"}, event.Payload)
+
+	seq, err := conn.Guess("apple")
+	require.Nil(t, err)
+	envelope := <-received
+	assert.Equal(t, MSG_CHAT, envelope.Type)
+	assert.Equal(t, seq, envelope.Seq)
+}
+
+func TestConnectWithInvalidSession(t *testing.T) {
+	server := fakeServer(t)
+	c := New(server.URL)
+	c.gameId = "abc123"
+	_, err := c.Connect()
+	statusErr, ok := err.(*StatusError)
+	require.True(t, ok, "Expected a StatusError")
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
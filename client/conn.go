@@ -0,0 +1,164 @@
+package client
+
+import (
+	"github.com/anchal00/doodle/internal/parser"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const WEBSOCKET_PROTOCOL = "doodle"
+const TOKEN_PROTOCOL_PREFIX = "doodle.token."
+
+// Event is a message received from the server. Payload points to the event
+// matching Type, e.g. a *TurnStartEvent for MSG_TURN_START. It is nil for
+// types added by a newer server that this client doesn't know about
+type Event struct {
+	Type    string
+	Seq     uint64
+	Payload any
+}
+
+// Conn is the player's websocket connection to their game. Sends may come from
+// any goroutine, events are to be received from a single one
+type Conn struct {
+	conn *websocket.Conn
+	mut  *sync.Mutex
+	seq  uint64
+}
+
+// Connect opens the websocket of the game the player created or joined. The
+// server greets the player with a MSG_CANVAS_SNAPSHOT followed by a MSG_LOBBY
+func (c *Client) Connect() (*Conn, error) {
+	if len(c.gameId) == 0 {
+		return nil, ErrNoGame
+	}
+	wsURL := c.baseURL + API_V1_PREFIX + fmt.Sprintf("/connect/game/%s", c.gameId)
+	if rest, found := strings.CutPrefix(wsURL, "http"); found {
+		wsURL = "ws" + rest
+	}
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+		Subprotocols:     []string{WEBSOCKET_PROTOCOL},
+	}
+	header := http.Header{}
+	// Browsers can't set an Authorization header on the handshake, the server
+	// reads the token from the offered subprotocols instead
+	if len(c.token) != 0 {
+		dialer.Subprotocols = append(dialer.Subprotocols, TOKEN_PROTOCOL_PREFIX+c.token)
+	} else {
+		c.authorize(header)
+	}
+	conn, resp, err := dialer.Dial(wsURL, header)
+	if resp != nil {
+		c.keepCookie(resp)
+	}
+	if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+		return nil, &StatusError{Method: http.MethodGet, Path: fmt.Sprintf("/connect/game/%s", c.gameId), StatusCode: resp.StatusCode}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, mut: &sync.Mutex{}}, nil
+}
+
+// Send sends a message of msgType and returns its sequence number, the server
+// answers a message it rejects with an *ErrorEvent whose InReplyTo is that number
+func (c *Conn) Send(msgType string, payload any) (uint64, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.seq += 1
+	data, err := parser.Encode(msgType, c.seq, payload)
+	if err != nil {
+		return 0, err
+	}
+	return c.seq, c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Draw sends a stroke, only the drawer may draw
+func (c *Conn) Draw(stroke Stroke) (uint64, error) {
+	return c.Send(MSG_STROKE, stroke)
+}
+
+// Undo erases the drawer's last stroke
+func (c *Conn) Undo() (uint64, error) {
+	return c.Send(MSG_UNDO, parser.UndoInput{})
+}
+
+// Redo draws the last undone stroke again
+func (c *Conn) Redo() (uint64, error) {
+	return c.Send(MSG_REDO, parser.RedoInput{})
+}
+
+// ClearCanvas erases everything the drawer has drawn
+func (c *Conn) ClearCanvas() (uint64, error) {
+	return c.Send(MSG_CLEAR, parser.ClearCanvasInput{})
+}
+
+// Guess sends text to the chat, it scores when it matches the word being drawn
+func (c *Conn) Guess(text string) (uint64, error) {
+	return c.Send(MSG_CHAT, ChatInput{Text: text})
+}
+
+// ChooseWord picks one of the words offered in a *WordChoicesEvent
+func (c *Conn) ChooseWord(word string) (uint64, error) {
+	return c.Send(MSG_CHOOSE_WORD, ChooseWordInput{Word: word})
+}
+
+// VoteKick votes to kick target out of the game
+func (c *Conn) VoteKick(target string) (uint64, error) {
+	return c.Send(MSG_VOTE_KICK, VoteKickInput{Target: target})
+}
+
+// Receive blocks until the server sends the next event
+func (c *Conn) Receive() (*Event, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	envelope, payload, err := parser.DecodeServerMessage(data)
+	var protocolErr *parser.ProtocolError
+	if errors.As(err, &protocolErr) && protocolErr.Code == parser.ERR_UNKNOWN_TYPE {
+		return &Event{Type: envelope.Type, Seq: envelope.Seq}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Type: envelope.Type, Seq: envelope.Seq, Payload: payload}, nil
+}
+
+// WaitFor receives events until one of msgTypes comes in, skipping the others
+func (c *Conn) WaitFor(msgTypes ...string) (*Event, error) {
+	for {
+		event, err := c.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(msgTypes, event.Type) {
+			return event, nil
+		}
+	}
+}
+
+// SetReadDeadline makes Receive and WaitFor fail once t has passed, a zero t
+// means they wait for as long as it takes
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Close leaves the game's websocket. The player keeps their seat for as long
+// as the server lets disconnected players reconnect
+func (c *Conn) Close() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	_ = c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+	return c.conn.Close()
+}
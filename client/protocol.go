@@ -0,0 +1,99 @@
+package client
+
+import "github.com/anchal00/doodle/internal/parser"
+
+// The requests, messages and events exchanged with the server, re-exported
+// since the parser package is internal to the server
+
+type (
+	CreateGameRequest  = parser.CreateGameRequest
+	CreateGameResponse = parser.CreateGameResponse
+	JoinGameRequest    = parser.JoinGameRequest
+	JoinGameResponse   = parser.JoinGameResponse
+	ScoringRules       = parser.ScoringRules
+)
+
+// Messages sent by players
+type (
+	Stroke          = parser.Stroke
+	Point           = parser.Point
+	Canvas          = parser.Canvas
+	ChatInput       = parser.ChatInput
+	ChooseWordInput = parser.ChooseWordInput
+	VoteKickInput   = parser.VoteKickInput
+)
+
+// Events sent by the server
+type (
+	StrokeEvent         = parser.StrokeEvent
+	UndoEvent           = parser.UndoEvent
+	ClearCanvasEvent    = parser.ClearCanvasEvent
+	ChatEvent           = parser.ChatEvent
+	CanvasSnapshotEvent = parser.CanvasSnapshotEvent
+	Lobby               = parser.Lobby
+	LobbyPlayer         = parser.LobbyPlayer
+	GameSettings        = parser.GameSettings
+	PlayerJoinedEvent   = parser.PlayerJoinedEvent
+	PlayerLeftEvent     = parser.PlayerLeftEvent
+	AdminChangedEvent   = parser.AdminChangedEvent
+	KickVoteEvent       = parser.KickVoteEvent
+	KickVoteEndedEvent  = parser.KickVoteEndedEvent
+	ChoosingWordEvent   = parser.ChoosingWordEvent
+	WordChoicesEvent    = parser.WordChoicesEvent
+	WordSelectedEvent   = parser.WordSelectedEvent
+	TurnStartEvent      = parser.TurnStartEvent
+	HintEvent           = parser.HintEvent
+	TurnEndEvent        = parser.TurnEndEvent
+	Standing            = parser.Standing
+	RoundEndEvent       = parser.RoundEndEvent
+	GameOverEvent       = parser.GameOverEvent
+	RematchEvent        = parser.RematchEvent
+	CorrectGuessEvent   = parser.CorrectGuessEvent
+	CloseGuessEvent     = parser.CloseGuessEvent
+	ScoreUpdateEvent    = parser.ScoreUpdateEvent
+	ErrorEvent          = parser.ErrorEvent
+)
+
+const (
+	MSG_STROKE          = parser.MSG_STROKE
+	MSG_CHAT            = parser.MSG_CHAT
+	MSG_CHOOSE_WORD     = parser.MSG_CHOOSE_WORD
+	MSG_UNDO            = parser.MSG_UNDO
+	MSG_REDO            = parser.MSG_REDO
+	MSG_CLEAR           = parser.MSG_CLEAR
+	MSG_VOTE_KICK       = parser.MSG_VOTE_KICK
+	MSG_CANVAS_SNAPSHOT = parser.MSG_CANVAS_SNAPSHOT
+	MSG_LOBBY           = parser.MSG_LOBBY
+	MSG_PLAYER_JOINED   = parser.MSG_PLAYER_JOINED
+	MSG_PLAYER_LEFT     = parser.MSG_PLAYER_LEFT
+	MSG_ADMIN_CHANGED   = parser.MSG_ADMIN_CHANGED
+	MSG_KICK_VOTE       = parser.MSG_KICK_VOTE
+	MSG_KICK_VOTE_ENDED = parser.MSG_KICK_VOTE_ENDED
+	MSG_CHOOSING_WORD   = parser.MSG_CHOOSING_WORD
+	MSG_WORD_CHOICES    = parser.MSG_WORD_CHOICES
+	MSG_WORD_SELECTED   = parser.MSG_WORD_SELECTED
+	MSG_TURN_START      = parser.MSG_TURN_START
+	MSG_HINT            = parser.MSG_HINT
+	MSG_TURN_END        = parser.MSG_TURN_END
+	MSG_ROUND_END       = parser.MSG_ROUND_END
+	MSG_GAME_OVER       = parser.MSG_GAME_OVER
+	MSG_REMATCH         = parser.MSG_REMATCH
+	MSG_CORRECT_GUESS   = parser.MSG_CORRECT_GUESS
+	MSG_CLOSE_GUESS     = parser.MSG_CLOSE_GUESS
+	MSG_SCORE_UPDATE    = parser.MSG_SCORE_UPDATE
+	MSG_ERROR           = parser.MSG_ERROR
+)
+
+const (
+	TOOL_PEN    = parser.TOOL_PEN
+	TOOL_ERASER = parser.TOOL_ERASER
+	TOOL_FILL   = parser.TOOL_FILL
+)
+
+// Reasons for a player leaving the game, see PlayerLeftEvent
+const (
+	LEFT_DISCONNECTED = parser.LEFT_DISCONNECTED
+	LEFT_QUIT         = parser.LEFT_QUIT
+	LEFT_KICKED       = parser.LEFT_KICKED
+	LEFT_TIMED_OUT    = parser.LEFT_TIMED_OUT
+)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/anchal00/doodle/internal/server"
+	"flag"
 	"log/slog"
 	"os"
 
@@ -9,6 +10,8 @@ import (
 )
 
 func main() {
+	allowedOrigins := flag.String("allowed-origins", "", "Comma separated origins allowed to use the API from a browser, overrides DOODLE_ALLOWED_ORIGINS")
+	flag.Parse()
 	err := godotenv.Load()
 	if err != nil {
 		slog.Error("Failed to load .env file")
@@ -20,8 +23,12 @@ func main() {
 		slog.Error("Env DOODLE_PORT not set")
 		return
 	}
+	if len(*allowedOrigins) != 0 {
+		os.Setenv("DOODLE_ALLOWED_ORIGINS", *allowedOrigins)
+	}
 	gs, err := server.NewGameServer(port)
 	if err != nil {
+		slog.Error("Failed to create game server", "error", err)
 		return
 	}
 	gs.Run()
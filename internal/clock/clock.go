@@ -0,0 +1,128 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is where games and the server read the time and set their timers, so
+// that tests can swap the wall clock for a Fake one
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls fn on its own goroutine once d has passed
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is a pending call to the function given to Clock.AfterFunc
+type Timer interface {
+	// Stop cancels the call, it returns false if the call already happened
+	// or had already been cancelled
+	Stop() bool
+}
+
+type systemClock struct{}
+
+// New returns the wall clock
+func New() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+// Fake is a Clock whose time only moves when Advance is called
+type Fake struct {
+	mut    *sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	fn    func()
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{mut: &sync.Mutex{}, now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.now
+}
+
+// AfterFunc calls fn once the clock has been advanced by d. Like the wall
+// clock's, fn is called right away on its own goroutine if d isn't positive
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	timer := &fakeTimer{clock: f, at: f.now.Add(d), fn: fn}
+	if d <= 0 {
+		go fn()
+		return timer
+	}
+	f.timers = append(f.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d, calling the functions of the timers
+// that run out on the way in order. They are called on the caller's goroutine,
+// with the clock set to their deadline
+func (f *Fake) Advance(d time.Duration) {
+	f.mut.Lock()
+	until := f.now.Add(d)
+	for {
+		next := f.next(until)
+		if next == nil {
+			break
+		}
+		f.now = next.at
+		f.mut.Unlock()
+		next.fn()
+		f.mut.Lock()
+	}
+	f.now = until
+	f.mut.Unlock()
+}
+
+// Pending is how many timers are yet to run out
+func (f *Fake) Pending() int {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return len(f.timers)
+}
+
+// next pops the earliest timer due by until, f.mut must be held
+func (f *Fake) next(until time.Time) *fakeTimer {
+	earliest := -1
+	for i, timer := range f.timers {
+		if !timer.at.After(until) && (earliest < 0 || timer.at.Before(f.timers[earliest].at)) {
+			earliest = i
+		}
+	}
+	if earliest < 0 {
+		return nil
+	}
+	timer := f.timers[earliest]
+	f.timers = slices.Delete(f.timers, earliest, earliest+1)
+	return timer
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mut.Lock()
+	defer t.clock.mut.Unlock()
+	i := slices.Index(t.clock.timers, t)
+	if i < 0 {
+		return false
+	}
+	t.clock.timers = slices.Delete(t.clock.timers, i, i+1)
+	return true
+}
@@ -0,0 +1,67 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeFiresTimersInOrder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFake(start)
+	fired := []string{}
+	record := func(name string) func() {
+		return func() { fired = append(fired, name+" "+clock.Now().Sub(start).String()) }
+	}
+	clock.AfterFunc(3*time.Second, record("third"))
+	clock.AfterFunc(time.Second, record("first"))
+	clock.AfterFunc(2*time.Second, record("second"))
+	assert.Equal(t, 3, clock.Pending())
+
+	clock.Advance(1500 * time.Millisecond)
+	assert.Equal(t, []string{"first 1s"}, fired)
+	assert.Equal(t, start.Add(1500*time.Millisecond), clock.Now())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, []string{"first 1s", "second 2s", "third 3s"}, fired)
+	assert.Equal(t, start.Add(time.Minute+1500*time.Millisecond), clock.Now())
+	assert.Zero(t, clock.Pending())
+}
+
+func TestFakeTimersSetWhileAdvancing(t *testing.T) {
+	clock := NewFake(time.Now())
+	fired := 0
+	clock.AfterFunc(time.Second, func() {
+		fired += 1
+		clock.AfterFunc(time.Second, func() { fired += 1 })
+	})
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, 2, fired, "Timers set by a firing timer fire within the same Advance")
+}
+
+func TestFakeStop(t *testing.T) {
+	clock := NewFake(time.Now())
+	fired := false
+	timer := clock.AfterFunc(time.Second, func() { fired = true })
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop(), "A timer can only be stopped once")
+	clock.Advance(time.Hour)
+	assert.False(t, fired)
+
+	timer = clock.AfterFunc(time.Second, func() {})
+	clock.Advance(time.Second)
+	assert.False(t, timer.Stop(), "A timer that fired can't be stopped")
+}
+
+func TestFakeFiresExpiredTimersRightAway(t *testing.T) {
+	clock := NewFake(time.Now())
+	fired := make(chan struct{})
+	clock.AfterFunc(-time.Second, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		assert.Fail(t, "Timer without a duration did not fire")
+	}
+	assert.Zero(t, clock.Pending())
+}
@@ -3,20 +3,38 @@ package db
 
 import (
 	"github.com/anchal00/doodle/internal/logger"
+	"errors"
+	"time"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrGameExists is returned by CreateNewGame when the game id is already taken
+var ErrGameExists = errors.New("Game id already taken")
+
 type Repository interface {
 	SetupConnection(database string) error
 	CloseConnection()
 	GetGameById(gameId string) *Game
 	GetGamePlayerByName(gameId, playerName string) Player
 	GetGamePlayers(gameId string) ([]Player, error)
-	GetGamePlayerByToken(gameId, token string) *Player
-	CreateNewGame(gameId, player, token string, maxPlayers, totalRounds uint8) error
-	AddPlayerToGame(gameId, playerName, token string) error
-	DeletePlayer(gameId, player string)
-	UpdatePlayerScore(gameId, playerName string, scoreDelta uint8) error
+	// GetGamePlayerByToken finds the player owning token unless it has expired by now
+	GetGamePlayerByToken(gameId, token string, now time.Time) *Player
+	CreateNewGame(gameId, player string, token SessionToken, maxPlayers, totalRounds, hints uint8, scoring ScoringRules) error
+	AddPlayerToGame(gameId, playerName string, token SessionToken) error
+	RefreshToken(gameId, playerName string, expiresAt time.Time) error
+	SetAdmin(gameId, playerName string) error
+	DeletePlayer(gameId, player string) error
+	UpdatePlayerScore(gameId, playerName string, scoreDelta uint) error
+	GetGameScores(gameId string) ([]Score, error)
+	ResetScores(gameId string) error
+	// DeleteGame removes the game along with its players, their scores and its snapshot
+	DeleteGame(gameId string) error
+	// SaveSnapshot replaces the last snapshot saved for the game
+	SaveSnapshot(gameId string, snapshot []byte, savedAt time.Time) error
+	GetSnapshots() ([]GameSnapshot, error)
+	AddWords(words []string) error
+	GetRandomWords(count uint8) ([]string, error)
 }
 
 func SetupDB(dbName string) (Repository, error) {
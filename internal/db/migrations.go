@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migration brings the tables of a database created by an older version of the
+// schema up to date. Migrations run in order, each of them once, the version a
+// database is at is kept in its user_version. A database created before
+// versioning was introduced is at version 0 whatever it holds, so migrations
+// skip the changes that are already in place
+type migration struct {
+	description string
+	apply       func(txn *sqlx.Tx) error
+}
+
+var migrations = []migration{
+	{"Add scoring rules to games", func(txn *sqlx.Tx) error {
+		return addColumns(txn, "games", [][2]string{
+			{"guess_max_points", "int DEFAULT 100 NOT NULL"},
+			{"guess_min_points", "int DEFAULT 20 NOT NULL"},
+			{"drawer_points", "int DEFAULT 25 NOT NULL"},
+		})
+	}},
+	{"Key scores by game and player", rebuildScores},
+	{"Add hints to games", func(txn *sqlx.Tx) error {
+		return addColumns(txn, "games", [][2]string{{"hints", "int DEFAULT 2 NOT NULL"}})
+	}},
+	{"Add session token expiry to players", func(txn *sqlx.Tx) error {
+		return addColumns(txn, "players", [][2]string{{"token_expires_at", "int DEFAULT 0 NOT NULL"}})
+	}},
+}
+
+// migrate applies the migrations the database is yet to go through
+func (s *SqliteStore) migrate() error {
+	var version int
+	if err := s.Conn.Get(&version, `PRAGMA user_version;`); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version += 1 {
+		next := migrations[version]
+		txn, err := s.Conn.Beginx()
+		if err != nil {
+			return err
+		}
+		if err := next.apply(txn); err != nil {
+			_ = txn.Rollback()
+			return fmt.Errorf("Migration %q failed: %w", next.description, err)
+		}
+		// PRAGMA doesn't take bound parameters
+		if _, err := txn.Exec(fmt.Sprintf(`PRAGMA user_version = %d;`, version+1)); err != nil {
+			_ = txn.Rollback()
+			return err
+		}
+		if err := txn.Commit(); err != nil {
+			return err
+		}
+		s.Logger.Info(fmt.Sprintf("Applied migration %d: %s", version+1, next.description))
+	}
+	return nil
+}
+
+// addColumns adds the columns, given as name and definition, that table lacks
+func addColumns(txn *sqlx.Tx, table string, columns [][2]string) error {
+	for _, column := range columns {
+		var found int
+		if err := txn.Get(&found, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`, table, column[0]); err != nil {
+			return err
+		}
+		if found != 0 {
+			continue
+		}
+		if _, err := txn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, table, column[0], column[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildScores recreates the scores table with its (game_id, player) key,
+// sqlite can't add a primary key to an existing table. Scores used to be
+// stored one row per round, those rows are summed up
+func rebuildScores(txn *sqlx.Tx) error {
+	var keyed int
+	if err := txn.Get(&keyed, `SELECT COUNT(*) FROM pragma_table_info('scores') WHERE pk > 0;`); err != nil {
+		return err
+	}
+	if keyed != 0 {
+		return nil
+	}
+	statements := []string{
+		`CREATE TABLE scores_keyed (
+  game_id varchar(32) REFERENCES games(game_id) ON DELETE CASCADE,
+  player varchar(10) NOT NULL,
+  score int DEFAULT 0 NOT NULL,
+  PRIMARY KEY (game_id, player),
+  FOREIGN KEY (player, game_id) REFERENCES players(name, game_id) ON DELETE CASCADE
+);`,
+		`INSERT INTO scores_keyed(game_id, player, score)
+  SELECT game_id, player, SUM(score) FROM scores
+  WHERE game_id IS NOT NULL AND player IS NOT NULL
+  GROUP BY game_id, player;`,
+		`DROP TABLE scores;`,
+		`ALTER TABLE scores_keyed RENAME TO scores;`,
+	}
+	for _, statement := range statements {
+		if _, err := txn.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
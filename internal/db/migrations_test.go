@@ -0,0 +1,82 @@
+package db
+
+import (
+	"github.com/anchal00/doodle/internal/logger"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// originalSchema is what the first release of the server created
+const originalSchema = `CREATE TABLE games (
+  game_id varchar(8) PRIMARY KEY,
+  player_count int DEFAULT 1 NOT NULL,
+  max_players int NOT NULL,
+  current_round int DEFAULT 1 NOT NULL,
+  total_rounds int NOT NULL
+);
+
+CREATE TABLE players (
+  name varchar(10) NOT NULL,
+  game_id varchar(8) REFERENCES games(game_id) ON DELETE CASCADE,
+  is_admin boolean DEFAULT false NOT NULL,
+  token varchar NOT NULL,
+  PRIMARY KEY (name, game_id)
+
+  CONSTRAINT non_empty_player CHECK (TRIM(name) <> '')
+);
+
+CREATE TABLE scores (
+  game_id varchar(8) REFERENCES games(game_id) ON DELETE CASCADE,
+  player varchar(10) REFERENCES players(name) ON DELETE CASCADE,
+  score int NOT NULL
+);
+
+INSERT INTO games(game_id, max_players, total_rounds) VALUES('abc123', 5, 3);
+INSERT INTO players(name, game_id, is_admin, token) VALUES('alice', 'abc123', true, 'token');
+INSERT INTO scores(game_id, player, score) VALUES('abc123', 'alice', 40), ('abc123', 'alice', 60);`
+
+func TestMigrateOriginalSchema(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "doodle")
+	original, err := sqlx.Connect("sqlite3", dbname+".db")
+	require.Nil(t, err)
+	original.MustExec(originalSchema)
+	require.Nil(t, original.Close())
+
+	store := &SqliteStore{Logger: logger.New("database")}
+	require.Nil(t, store.SetupConnection(dbname))
+	defer store.CloseConnection()
+
+	game := store.GetGameById("abc123")
+	require.NotNil(t, game)
+	assert.Equal(t, uint8(2), game.Hints)
+	assert.Equal(t, ScoringRules{GuessMaxPoints: 100, GuessMinPoints: 20, DrawerPoints: 25}, game.ScoringRules)
+	scores, err := store.GetGameScores("abc123")
+	require.Nil(t, err)
+	assert.Equal(t, []Score{{Player: "alice", Score: 100}}, scores, "Scores of every round are summed up")
+	require.Nil(t, store.UpdatePlayerScore("abc123", "alice", 20))
+	scores, err = store.GetGameScores("abc123")
+	require.Nil(t, err)
+	assert.Equal(t, []Score{{Player: "alice", Score: 120}}, scores)
+	assert.Nil(t, store.GetGamePlayerByToken("abc123", "token", time.Now()), "Tokens saved before they expired are no longer valid")
+	expiresAt := time.Now().Add(time.Hour)
+	require.Nil(t, store.AddPlayerToGame("abc123", "bob", SessionToken{Value: "secret", ExpiresAt: expiresAt}))
+	assert.NotNil(t, store.GetGamePlayerByToken("abc123", "secret", expiresAt.Add(-time.Second)))
+	assert.Nil(t, store.GetGamePlayerByToken("abc123", "secret", expiresAt.Add(time.Second)), "Tokens expire by the time given")
+}
+
+func TestMigrationsRunOnce(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "doodle")
+	for range 2 {
+		store := &SqliteStore{Logger: logger.New("database")}
+		require.Nil(t, store.SetupConnection(dbname))
+		var version int
+		require.Nil(t, store.Conn.Get(&version, `PRAGMA user_version;`))
+		assert.Equal(t, len(migrations), version)
+		store.CloseConnection()
+	}
+}
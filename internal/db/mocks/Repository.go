@@ -5,6 +5,8 @@ package mocks
 import (
 	db "github.com/anchal00/doodle/internal/db"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -21,7 +23,7 @@ func (_m *Repository) EXPECT() *Repository_Expecter {
 }
 
 // AddPlayerToGame provides a mock function with given fields: gameId, playerName, token
-func (_m *Repository) AddPlayerToGame(gameId string, playerName string, token string) error {
+func (_m *Repository) AddPlayerToGame(gameId string, playerName string, token db.SessionToken) error {
 	ret := _m.Called(gameId, playerName, token)
 
 	if len(ret) == 0 {
@@ -29,7 +31,7 @@ func (_m *Repository) AddPlayerToGame(gameId string, playerName string, token st
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+	if rf, ok := ret.Get(0).(func(string, string, db.SessionToken) error); ok {
 		r0 = rf(gameId, playerName, token)
 	} else {
 		r0 = ret.Error(0)
@@ -46,14 +48,14 @@ type Repository_AddPlayerToGame_Call struct {
 // AddPlayerToGame is a helper method to define mock.On call
 //   - gameId string
 //   - playerName string
-//   - token string
+//   - token db.SessionToken
 func (_e *Repository_Expecter) AddPlayerToGame(gameId interface{}, playerName interface{}, token interface{}) *Repository_AddPlayerToGame_Call {
 	return &Repository_AddPlayerToGame_Call{Call: _e.mock.On("AddPlayerToGame", gameId, playerName, token)}
 }
 
-func (_c *Repository_AddPlayerToGame_Call) Run(run func(gameId string, playerName string, token string)) *Repository_AddPlayerToGame_Call {
+func (_c *Repository_AddPlayerToGame_Call) Run(run func(gameId string, playerName string, token db.SessionToken)) *Repository_AddPlayerToGame_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(string), args[2].(string))
+		run(args[0].(string), args[1].(string), args[2].(db.SessionToken))
 	})
 	return _c
 }
@@ -63,7 +65,53 @@ func (_c *Repository_AddPlayerToGame_Call) Return(_a0 error) *Repository_AddPlay
 	return _c
 }
 
-func (_c *Repository_AddPlayerToGame_Call) RunAndReturn(run func(string, string, string) error) *Repository_AddPlayerToGame_Call {
+func (_c *Repository_AddPlayerToGame_Call) RunAndReturn(run func(string, string, db.SessionToken) error) *Repository_AddPlayerToGame_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// AddWords provides a mock function with given fields: words
+func (_m *Repository) AddWords(words []string) error {
+	ret := _m.Called(words)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddWords")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]string) error); ok {
+		r0 = rf(words)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_AddWords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddWords'
+type Repository_AddWords_Call struct {
+	*mock.Call
+}
+
+// AddWords is a helper method to define mock.On call
+//   - words []string
+func (_e *Repository_Expecter) AddWords(words interface{}) *Repository_AddWords_Call {
+	return &Repository_AddWords_Call{Call: _e.mock.On("AddWords", words)}
+}
+
+func (_c *Repository_AddWords_Call) Run(run func(words []string)) *Repository_AddWords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]string))
+	})
+	return _c
+}
+
+func (_c *Repository_AddWords_Call) Return(_a0 error) *Repository_AddWords_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_AddWords_Call) RunAndReturn(run func([]string) error) *Repository_AddWords_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -100,17 +148,17 @@ func (_c *Repository_CloseConnection_Call) RunAndReturn(run func()) *Repository_
 	return _c
 }
 
-// CreateNewGame provides a mock function with given fields: gameId, player, token, maxPlayers, totalRounds
-func (_m *Repository) CreateNewGame(gameId string, player string, token string, maxPlayers uint8, totalRounds uint8) error {
-	ret := _m.Called(gameId, player, token, maxPlayers, totalRounds)
+// CreateNewGame provides a mock function with given fields: gameId, player, token, maxPlayers, totalRounds, hints, scoring
+func (_m *Repository) CreateNewGame(gameId string, player string, token db.SessionToken, maxPlayers uint8, totalRounds uint8, hints uint8, scoring db.ScoringRules) error {
+	ret := _m.Called(gameId, player, token, maxPlayers, totalRounds, hints, scoring)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateNewGame")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, string, uint8, uint8) error); ok {
-		r0 = rf(gameId, player, token, maxPlayers, totalRounds)
+	if rf, ok := ret.Get(0).(func(string, string, db.SessionToken, uint8, uint8, uint8, db.ScoringRules) error); ok {
+		r0 = rf(gameId, player, token, maxPlayers, totalRounds, hints, scoring)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -126,16 +174,18 @@ type Repository_CreateNewGame_Call struct {
 // CreateNewGame is a helper method to define mock.On call
 //   - gameId string
 //   - player string
-//   - token string
+//   - token db.SessionToken
 //   - maxPlayers uint8
 //   - totalRounds uint8
-func (_e *Repository_Expecter) CreateNewGame(gameId interface{}, player interface{}, token interface{}, maxPlayers interface{}, totalRounds interface{}) *Repository_CreateNewGame_Call {
-	return &Repository_CreateNewGame_Call{Call: _e.mock.On("CreateNewGame", gameId, player, token, maxPlayers, totalRounds)}
+//   - hints uint8
+//   - scoring db.ScoringRules
+func (_e *Repository_Expecter) CreateNewGame(gameId interface{}, player interface{}, token interface{}, maxPlayers interface{}, totalRounds interface{}, hints interface{}, scoring interface{}) *Repository_CreateNewGame_Call {
+	return &Repository_CreateNewGame_Call{Call: _e.mock.On("CreateNewGame", gameId, player, token, maxPlayers, totalRounds, hints, scoring)}
 }
 
-func (_c *Repository_CreateNewGame_Call) Run(run func(gameId string, player string, token string, maxPlayers uint8, totalRounds uint8)) *Repository_CreateNewGame_Call {
+func (_c *Repository_CreateNewGame_Call) Run(run func(gameId string, player string, token db.SessionToken, maxPlayers uint8, totalRounds uint8, hints uint8, scoring db.ScoringRules)) *Repository_CreateNewGame_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(string), args[2].(string), args[3].(uint8), args[4].(uint8))
+		run(args[0].(string), args[1].(string), args[2].(db.SessionToken), args[3].(uint8), args[4].(uint8), args[5].(uint8), args[6].(db.ScoringRules))
 	})
 	return _c
 }
@@ -145,14 +195,73 @@ func (_c *Repository_CreateNewGame_Call) Return(_a0 error) *Repository_CreateNew
 	return _c
 }
 
-func (_c *Repository_CreateNewGame_Call) RunAndReturn(run func(string, string, string, uint8, uint8) error) *Repository_CreateNewGame_Call {
+func (_c *Repository_CreateNewGame_Call) RunAndReturn(run func(string, string, db.SessionToken, uint8, uint8, uint8, db.ScoringRules) error) *Repository_CreateNewGame_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteGame provides a mock function with given fields: gameId
+func (_m *Repository) DeleteGame(gameId string) error {
+	ret := _m.Called(gameId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteGame")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(gameId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_DeleteGame_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteGame'
+type Repository_DeleteGame_Call struct {
+	*mock.Call
+}
+
+// DeleteGame is a helper method to define mock.On call
+//   - gameId string
+func (_e *Repository_Expecter) DeleteGame(gameId interface{}) *Repository_DeleteGame_Call {
+	return &Repository_DeleteGame_Call{Call: _e.mock.On("DeleteGame", gameId)}
+}
+
+func (_c *Repository_DeleteGame_Call) Run(run func(gameId string)) *Repository_DeleteGame_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_DeleteGame_Call) Return(_a0 error) *Repository_DeleteGame_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_DeleteGame_Call) RunAndReturn(run func(string) error) *Repository_DeleteGame_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // DeletePlayer provides a mock function with given fields: gameId, player
-func (_m *Repository) DeletePlayer(gameId string, player string) {
-	_m.Called(gameId, player)
+func (_m *Repository) DeletePlayer(gameId string, player string) error {
+	ret := _m.Called(gameId, player)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePlayer")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(gameId, player)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // Repository_DeletePlayer_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeletePlayer'
@@ -174,13 +283,13 @@ func (_c *Repository_DeletePlayer_Call) Run(run func(gameId string, player strin
 	return _c
 }
 
-func (_c *Repository_DeletePlayer_Call) Return() *Repository_DeletePlayer_Call {
-	_c.Call.Return()
+func (_c *Repository_DeletePlayer_Call) Return(_a0 error) *Repository_DeletePlayer_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *Repository_DeletePlayer_Call) RunAndReturn(run func(string, string)) *Repository_DeletePlayer_Call {
-	_c.Run(run)
+func (_c *Repository_DeletePlayer_Call) RunAndReturn(run func(string, string) error) *Repository_DeletePlayer_Call {
+	_c.Call.Return(run)
 	return _c
 }
 
@@ -279,17 +388,17 @@ func (_c *Repository_GetGamePlayerByName_Call) RunAndReturn(run func(string, str
 	return _c
 }
 
-// GetGamePlayerByToken provides a mock function with given fields: gameId, token
-func (_m *Repository) GetGamePlayerByToken(gameId string, token string) *db.Player {
-	ret := _m.Called(gameId, token)
+// GetGamePlayerByToken provides a mock function with given fields: gameId, token, now
+func (_m *Repository) GetGamePlayerByToken(gameId string, token string, now time.Time) *db.Player {
+	ret := _m.Called(gameId, token, now)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetGamePlayerByToken")
 	}
 
 	var r0 *db.Player
-	if rf, ok := ret.Get(0).(func(string, string) *db.Player); ok {
-		r0 = rf(gameId, token)
+	if rf, ok := ret.Get(0).(func(string, string, time.Time) *db.Player); ok {
+		r0 = rf(gameId, token, now)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*db.Player)
@@ -307,13 +416,14 @@ type Repository_GetGamePlayerByToken_Call struct {
 // GetGamePlayerByToken is a helper method to define mock.On call
 //   - gameId string
 //   - token string
-func (_e *Repository_Expecter) GetGamePlayerByToken(gameId interface{}, token interface{}) *Repository_GetGamePlayerByToken_Call {
-	return &Repository_GetGamePlayerByToken_Call{Call: _e.mock.On("GetGamePlayerByToken", gameId, token)}
+//   - now time.Time
+func (_e *Repository_Expecter) GetGamePlayerByToken(gameId interface{}, token interface{}, now interface{}) *Repository_GetGamePlayerByToken_Call {
+	return &Repository_GetGamePlayerByToken_Call{Call: _e.mock.On("GetGamePlayerByToken", gameId, token, now)}
 }
 
-func (_c *Repository_GetGamePlayerByToken_Call) Run(run func(gameId string, token string)) *Repository_GetGamePlayerByToken_Call {
+func (_c *Repository_GetGamePlayerByToken_Call) Run(run func(gameId string, token string, now time.Time)) *Repository_GetGamePlayerByToken_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(string))
+		run(args[0].(string), args[1].(string), args[2].(time.Time))
 	})
 	return _c
 }
@@ -323,7 +433,7 @@ func (_c *Repository_GetGamePlayerByToken_Call) Return(_a0 *db.Player) *Reposito
 	return _c
 }
 
-func (_c *Repository_GetGamePlayerByToken_Call) RunAndReturn(run func(string, string) *db.Player) *Repository_GetGamePlayerByToken_Call {
+func (_c *Repository_GetGamePlayerByToken_Call) RunAndReturn(run func(string, string, time.Time) *db.Player) *Repository_GetGamePlayerByToken_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -386,6 +496,368 @@ func (_c *Repository_GetGamePlayers_Call) RunAndReturn(run func(string) ([]db.Pl
 	return _c
 }
 
+// GetGameScores provides a mock function with given fields: gameId
+func (_m *Repository) GetGameScores(gameId string) ([]db.Score, error) {
+	ret := _m.Called(gameId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetGameScores")
+	}
+
+	var r0 []db.Score
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]db.Score, error)); ok {
+		return rf(gameId)
+	}
+	if rf, ok := ret.Get(0).(func(string) []db.Score); ok {
+		r0 = rf(gameId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.Score)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(gameId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetGameScores_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetGameScores'
+type Repository_GetGameScores_Call struct {
+	*mock.Call
+}
+
+// GetGameScores is a helper method to define mock.On call
+//   - gameId string
+func (_e *Repository_Expecter) GetGameScores(gameId interface{}) *Repository_GetGameScores_Call {
+	return &Repository_GetGameScores_Call{Call: _e.mock.On("GetGameScores", gameId)}
+}
+
+func (_c *Repository_GetGameScores_Call) Run(run func(gameId string)) *Repository_GetGameScores_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_GetGameScores_Call) Return(_a0 []db.Score, _a1 error) *Repository_GetGameScores_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetGameScores_Call) RunAndReturn(run func(string) ([]db.Score, error)) *Repository_GetGameScores_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRandomWords provides a mock function with given fields: count
+func (_m *Repository) GetRandomWords(count uint8) ([]string, error) {
+	ret := _m.Called(count)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRandomWords")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uint8) ([]string, error)); ok {
+		return rf(count)
+	}
+	if rf, ok := ret.Get(0).(func(uint8) []string); ok {
+		r0 = rf(count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint8) error); ok {
+		r1 = rf(count)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetRandomWords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRandomWords'
+type Repository_GetRandomWords_Call struct {
+	*mock.Call
+}
+
+// GetRandomWords is a helper method to define mock.On call
+//   - count uint8
+func (_e *Repository_Expecter) GetRandomWords(count interface{}) *Repository_GetRandomWords_Call {
+	return &Repository_GetRandomWords_Call{Call: _e.mock.On("GetRandomWords", count)}
+}
+
+func (_c *Repository_GetRandomWords_Call) Run(run func(count uint8)) *Repository_GetRandomWords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint8))
+	})
+	return _c
+}
+
+func (_c *Repository_GetRandomWords_Call) Return(_a0 []string, _a1 error) *Repository_GetRandomWords_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetRandomWords_Call) RunAndReturn(run func(uint8) ([]string, error)) *Repository_GetRandomWords_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSnapshots provides a mock function with no fields
+func (_m *Repository) GetSnapshots() ([]db.GameSnapshot, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSnapshots")
+	}
+
+	var r0 []db.GameSnapshot
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]db.GameSnapshot, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []db.GameSnapshot); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.GameSnapshot)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetSnapshots_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSnapshots'
+type Repository_GetSnapshots_Call struct {
+	*mock.Call
+}
+
+// GetSnapshots is a helper method to define mock.On call
+func (_e *Repository_Expecter) GetSnapshots() *Repository_GetSnapshots_Call {
+	return &Repository_GetSnapshots_Call{Call: _e.mock.On("GetSnapshots")}
+}
+
+func (_c *Repository_GetSnapshots_Call) Run(run func()) *Repository_GetSnapshots_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Repository_GetSnapshots_Call) Return(_a0 []db.GameSnapshot, _a1 error) *Repository_GetSnapshots_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetSnapshots_Call) RunAndReturn(run func() ([]db.GameSnapshot, error)) *Repository_GetSnapshots_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RefreshToken provides a mock function with given fields: gameId, playerName, expiresAt
+func (_m *Repository) RefreshToken(gameId string, playerName string, expiresAt time.Time) error {
+	ret := _m.Called(gameId, playerName, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, time.Time) error); ok {
+		r0 = rf(gameId, playerName, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_RefreshToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RefreshToken'
+type Repository_RefreshToken_Call struct {
+	*mock.Call
+}
+
+// RefreshToken is a helper method to define mock.On call
+//   - gameId string
+//   - playerName string
+//   - expiresAt time.Time
+func (_e *Repository_Expecter) RefreshToken(gameId interface{}, playerName interface{}, expiresAt interface{}) *Repository_RefreshToken_Call {
+	return &Repository_RefreshToken_Call{Call: _e.mock.On("RefreshToken", gameId, playerName, expiresAt)}
+}
+
+func (_c *Repository_RefreshToken_Call) Run(run func(gameId string, playerName string, expiresAt time.Time)) *Repository_RefreshToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *Repository_RefreshToken_Call) Return(_a0 error) *Repository_RefreshToken_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_RefreshToken_Call) RunAndReturn(run func(string, string, time.Time) error) *Repository_RefreshToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResetScores provides a mock function with given fields: gameId
+func (_m *Repository) ResetScores(gameId string) error {
+	ret := _m.Called(gameId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetScores")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(gameId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_ResetScores_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResetScores'
+type Repository_ResetScores_Call struct {
+	*mock.Call
+}
+
+// ResetScores is a helper method to define mock.On call
+//   - gameId string
+func (_e *Repository_Expecter) ResetScores(gameId interface{}) *Repository_ResetScores_Call {
+	return &Repository_ResetScores_Call{Call: _e.mock.On("ResetScores", gameId)}
+}
+
+func (_c *Repository_ResetScores_Call) Run(run func(gameId string)) *Repository_ResetScores_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_ResetScores_Call) Return(_a0 error) *Repository_ResetScores_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_ResetScores_Call) RunAndReturn(run func(string) error) *Repository_ResetScores_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveSnapshot provides a mock function with given fields: gameId, snapshot, savedAt
+func (_m *Repository) SaveSnapshot(gameId string, snapshot []byte, savedAt time.Time) error {
+	ret := _m.Called(gameId, snapshot, savedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveSnapshot")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte, time.Time) error); ok {
+		r0 = rf(gameId, snapshot, savedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_SaveSnapshot_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveSnapshot'
+type Repository_SaveSnapshot_Call struct {
+	*mock.Call
+}
+
+// SaveSnapshot is a helper method to define mock.On call
+//   - gameId string
+//   - snapshot []byte
+//   - savedAt time.Time
+func (_e *Repository_Expecter) SaveSnapshot(gameId interface{}, snapshot interface{}, savedAt interface{}) *Repository_SaveSnapshot_Call {
+	return &Repository_SaveSnapshot_Call{Call: _e.mock.On("SaveSnapshot", gameId, snapshot, savedAt)}
+}
+
+func (_c *Repository_SaveSnapshot_Call) Run(run func(gameId string, snapshot []byte, savedAt time.Time)) *Repository_SaveSnapshot_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].([]byte), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *Repository_SaveSnapshot_Call) Return(_a0 error) *Repository_SaveSnapshot_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_SaveSnapshot_Call) RunAndReturn(run func(string, []byte, time.Time) error) *Repository_SaveSnapshot_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetAdmin provides a mock function with given fields: gameId, playerName
+func (_m *Repository) SetAdmin(gameId string, playerName string) error {
+	ret := _m.Called(gameId, playerName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetAdmin")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(gameId, playerName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_SetAdmin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAdmin'
+type Repository_SetAdmin_Call struct {
+	*mock.Call
+}
+
+// SetAdmin is a helper method to define mock.On call
+//   - gameId string
+//   - playerName string
+func (_e *Repository_Expecter) SetAdmin(gameId interface{}, playerName interface{}) *Repository_SetAdmin_Call {
+	return &Repository_SetAdmin_Call{Call: _e.mock.On("SetAdmin", gameId, playerName)}
+}
+
+func (_c *Repository_SetAdmin_Call) Run(run func(gameId string, playerName string)) *Repository_SetAdmin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_SetAdmin_Call) Return(_a0 error) *Repository_SetAdmin_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_SetAdmin_Call) RunAndReturn(run func(string, string) error) *Repository_SetAdmin_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetupConnection provides a mock function with given fields: database
 func (_m *Repository) SetupConnection(database string) error {
 	ret := _m.Called(database)
@@ -433,7 +905,7 @@ func (_c *Repository_SetupConnection_Call) RunAndReturn(run func(string) error)
 }
 
 // UpdatePlayerScore provides a mock function with given fields: gameId, playerName, scoreDelta
-func (_m *Repository) UpdatePlayerScore(gameId string, playerName string, scoreDelta uint8) error {
+func (_m *Repository) UpdatePlayerScore(gameId string, playerName string, scoreDelta uint) error {
 	ret := _m.Called(gameId, playerName, scoreDelta)
 
 	if len(ret) == 0 {
@@ -441,7 +913,7 @@ func (_m *Repository) UpdatePlayerScore(gameId string, playerName string, scoreD
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, uint8) error); ok {
+	if rf, ok := ret.Get(0).(func(string, string, uint) error); ok {
 		r0 = rf(gameId, playerName, scoreDelta)
 	} else {
 		r0 = ret.Error(0)
@@ -458,14 +930,14 @@ type Repository_UpdatePlayerScore_Call struct {
 // UpdatePlayerScore is a helper method to define mock.On call
 //   - gameId string
 //   - playerName string
-//   - scoreDelta uint8
+//   - scoreDelta uint
 func (_e *Repository_Expecter) UpdatePlayerScore(gameId interface{}, playerName interface{}, scoreDelta interface{}) *Repository_UpdatePlayerScore_Call {
 	return &Repository_UpdatePlayerScore_Call{Call: _e.mock.On("UpdatePlayerScore", gameId, playerName, scoreDelta)}
 }
 
-func (_c *Repository_UpdatePlayerScore_Call) Run(run func(gameId string, playerName string, scoreDelta uint8)) *Repository_UpdatePlayerScore_Call {
+func (_c *Repository_UpdatePlayerScore_Call) Run(run func(gameId string, playerName string, scoreDelta uint)) *Repository_UpdatePlayerScore_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(string), args[2].(uint8))
+		run(args[0].(string), args[1].(string), args[2].(uint))
 	})
 	return _c
 }
@@ -475,7 +947,7 @@ func (_c *Repository_UpdatePlayerScore_Call) Return(_a0 error) *Repository_Updat
 	return _c
 }
 
-func (_c *Repository_UpdatePlayerScore_Call) RunAndReturn(run func(string, string, uint8) error) *Repository_UpdatePlayerScore_Call {
+func (_c *Repository_UpdatePlayerScore_Call) RunAndReturn(run func(string, string, uint) error) *Repository_UpdatePlayerScore_Call {
 	_c.Call.Return(run)
 	return _c
 }
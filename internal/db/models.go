@@ -1,16 +1,52 @@
 package db
 
+import "time"
+
 type Game struct {
 	GameId       string `db:"game_id"`
 	PlayerCount  uint8  `db:"player_count"`
 	MaxPlayers   uint8  `db:"max_players"`
 	CurrentRound uint8  `db:"current_round"`
 	TotalRounds  uint8  `db:"total_rounds"`
+	Hints        uint8  `db:"hints"`
+	ScoringRules
+}
+
+// ScoringRules decide how many points a turn is worth. A guesser earns between
+// GuessMinPoints and GuessMaxPoints depending on how early they guessed, the
+// drawer earns DrawerPoints for every player who guessed their word
+type ScoringRules struct {
+	GuessMaxPoints uint8 `db:"guess_max_points"`
+	GuessMinPoints uint8 `db:"guess_min_points"`
+	DrawerPoints   uint8 `db:"drawer_points"`
+}
+
+// Score is a player's total over every round of a game played so far
+type Score struct {
+	Player string `db:"player"`
+	Score  uint   `db:"score"`
 }
 
+// Player's AuthToken is the hash of their session token, the token itself is
+// never stored. TokenExpiresAt is a unix timestamp
 type Player struct {
-	Name      string `db:"name"`
+	Name           string `db:"name"`
+	GameId         string `db:"game_id"`
+	IsAdmin        bool   `db:"is_admin"`
+	AuthToken      string `db:"token"`
+	TokenExpiresAt int64  `db:"token_expires_at"`
+}
+
+// GameSnapshot is the serialized state of a game in progress, it lets games
+// resume after a restart. UpdatedAt is a unix timestamp
+type GameSnapshot struct {
 	GameId    string `db:"game_id"`
-	IsAdmin   bool   `db:"is_admin"`
-	AuthToken string `db:"token"`
+	Snapshot  []byte `db:"snapshot"`
+	UpdatedAt int64  `db:"updated_at"`
+}
+
+// SessionToken is handed out to a player when they create or join a game
+type SessionToken struct {
+	Value     string
+	ExpiresAt time.Time
 }
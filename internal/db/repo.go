@@ -1,34 +1,57 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/anchal00/doodle/internal/logger"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
 )
 
 var schema = `CREATE TABLE IF NOT EXISTS games (
-  game_id varchar(8) PRIMARY KEY,
+  game_id varchar(32) PRIMARY KEY,
   player_count int DEFAULT 1 NOT NULL,
   max_players int NOT NULL,
   current_round int DEFAULT 1 NOT NULL,
-  total_rounds int NOT NULL
+  total_rounds int NOT NULL,
+  hints int DEFAULT 2 NOT NULL,
+  guess_max_points int DEFAULT 100 NOT NULL,
+  guess_min_points int DEFAULT 20 NOT NULL,
+  drawer_points int DEFAULT 25 NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS players (
   name varchar(10) NOT NULL,
-  game_id varchar(8) REFERENCES games(game_id) ON DELETE CASCADE,
+  game_id varchar(32) REFERENCES games(game_id) ON DELETE CASCADE,
   is_admin boolean DEFAULT false NOT NULL,
   token varchar NOT NULL,
+  token_expires_at int DEFAULT 0 NOT NULL,
   PRIMARY KEY (name, game_id)
 
   CONSTRAINT non_empty_player CHECK (TRIM(name) <> '')
 );
 
 CREATE TABLE IF NOT EXISTS scores (
-  game_id varchar(8) REFERENCES games(game_id) ON DELETE CASCADE,
-  player varchar(10) REFERENCES players(name) ON DELETE CASCADE,
-  score int NOT NULL
+  game_id varchar(32) REFERENCES games(game_id) ON DELETE CASCADE,
+  player varchar(10) NOT NULL,
+  score int DEFAULT 0 NOT NULL,
+  PRIMARY KEY (game_id, player),
+  FOREIGN KEY (player, game_id) REFERENCES players(name, game_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS game_snapshots (
+  game_id varchar(32) PRIMARY KEY REFERENCES games(game_id) ON DELETE CASCADE,
+  snapshot text NOT NULL,
+  updated_at int NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS words (
+  word varchar(32) PRIMARY KEY,
+
+  CONSTRAINT non_empty_word CHECK (TRIM(word) <> '')
 );`
 
 type SqliteStore struct {
@@ -44,7 +67,13 @@ func (s *SqliteStore) SetupConnection(dbname string) error {
 		return err
 	}
 	s.Conn = db
+	// New tables are created right away, the ones already there are migrated
 	s.Conn.MustExec(schema)
+	if err := s.migrate(); err != nil {
+		s.Logger.Error("Database migration failed", err)
+		db.Close()
+		return err
+	}
 	s.Logger.Info(fmt.Sprintf("Database %s setup successfully", sqlite_dbfile))
 	return nil
 }
@@ -70,24 +99,82 @@ func (s *SqliteStore) GetGameById(gameId string) *Game {
 	return game
 }
 
-func (s *SqliteStore) GetGamePlayerByToken(gameId, token string) *Player {
-	return &Player{}
+// GetGamePlayerByToken returns nil unless token belongs to a player of the game and is yet to expire
+func (s *SqliteStore) GetGamePlayerByToken(gameId, token string, now time.Time) *Player {
+	sql := `SELECT * FROM players WHERE game_id = ? AND token = ? AND token_expires_at > ?;`
+	player := &Player{}
+	err := s.Conn.Get(player, sql, gameId, hashToken(token), now.Unix())
+	if err != nil {
+		s.Logger.Error("Failed to find player by session token", err)
+		return nil
+	}
+	return player
 }
 
-func (s *SqliteStore) DeletePlayer(gameId, player string) {
-	sql := `DELETE FROM player WHERE game_id = ? AND player = ?`
-	_, err := s.Conn.Exec(sql, gameId, player)
+// RefreshToken pushes back the expiry of the player's session token
+func (s *SqliteStore) RefreshToken(gameId, playerName string, expiresAt time.Time) error {
+	sql := `UPDATE players SET token_expires_at = ? WHERE game_id = ? AND name = ?;`
+	_, err := s.Conn.Exec(sql, expiresAt.Unix(), gameId, playerName)
 	if err != nil {
-		s.Logger.Error("Failed to fetch game", err)
-		return
+		s.Logger.Error(fmt.Sprintf("Failed to refresh session token of player %s", playerName), err)
+		return err
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *SqliteStore) DeletePlayer(gameId, player string) error {
+	txn, err := s.Conn.Beginx()
+	if err != nil {
+		s.Logger.Error("Failed to delete player", err)
+		return err
+	}
+	deletePlayerSQL := `DELETE FROM players WHERE game_id = ? AND name = ?;`
+	result, err := txn.Exec(deletePlayerSQL, gameId, player)
+	if err == nil {
+		if deleted, _ := result.RowsAffected(); deleted == 0 {
+			err = fmt.Errorf("Player %s not found in game %s", player, gameId)
+		}
+	}
+	if err != nil {
+		s.Logger.Error("Failed to delete player", err)
+		errRoll := txn.Rollback()
+		if errRoll != nil {
+			s.Logger.Error("Failed to rollback DeletePlayer txn", errRoll)
+			return errRoll
+		}
+		return err
+	}
+	updatePlayerCountSQL := `UPDATE games SET player_count=player_count-1 WHERE game_id = ?;`
+	_, err = txn.Exec(updatePlayerCountSQL, gameId)
+	if err != nil {
+		s.Logger.Error("Failed to update player count", err)
+		errRoll := txn.Rollback()
+		if errRoll != nil {
+			s.Logger.Error("Failed to rollback DeletePlayer txn", errRoll)
+			return errRoll
+		}
+		return err
+	}
+
+	errCommit := txn.Commit()
+	if errCommit != nil {
+		s.Logger.Error("Failed to Commit DeletePlayer txn", errCommit)
+		return errCommit
 	}
 	s.Logger.Info(fmt.Sprintf("Player %s deleted from game %s", player, gameId))
+	return nil
 }
 
 func (s *SqliteStore) GetGamePlayers(gameId string) ([]Player, error) {
 	players := []Player{}
-	sql := `SELECT * FROM players WHERE game_id = ?`
-	err := s.Conn.Get(players, sql, gameId)
+	// Players are listed in the order they joined the game
+	sql := `SELECT * FROM players WHERE game_id = ? ORDER BY rowid`
+	err := s.Conn.Select(&players, sql, gameId)
 	if err != nil {
 		return nil, err
 	}
@@ -98,14 +185,15 @@ func (s *SqliteStore) GetGamePlayerByName(gameId, playerName string) Player {
 	return Player{}
 }
 
-func (s *SqliteStore) CreateNewGame(gameId, player, token string, maxPlayers, totalRounds uint8) error {
+func (s *SqliteStore) CreateNewGame(gameId, player string, token SessionToken, maxPlayers, totalRounds, hints uint8, scoring ScoringRules) error {
 	txn, err := s.Conn.Beginx()
 	if err != nil {
 		s.Logger.Error("Failed to create new game", err)
 		return err
 	}
-	createGameSQL := `INSERT INTO games(game_id, max_players, total_rounds) VALUES(?, ?, ?);`
-	_, err = txn.Exec(createGameSQL, gameId, maxPlayers, totalRounds)
+	createGameSQL := `INSERT INTO games(game_id, max_players, total_rounds, hints, guess_max_points, guess_min_points, drawer_points)
+	VALUES(?, ?, ?, ?, ?, ?, ?);`
+	_, err = txn.Exec(createGameSQL, gameId, maxPlayers, totalRounds, hints, scoring.GuessMaxPoints, scoring.GuessMinPoints, scoring.DrawerPoints)
 	if err != nil {
 		s.Logger.Error("Failed to create new game", err)
 		errRoll := txn.Rollback()
@@ -113,11 +201,14 @@ func (s *SqliteStore) CreateNewGame(gameId, player, token string, maxPlayers, to
 			s.Logger.Error("Failed to rollback CreateGame txn", errRoll)
 			return errRoll
 		}
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return ErrGameExists
+		}
 		return err
 	}
 	s.Logger.Info("Game created successfully")
-	insertPlayerSQL := `INSERT INTO players(name, game_id, is_admin, token) VALUES(?, ?, ?, ?);`
-	_, err = txn.Exec(insertPlayerSQL, player, gameId, true, token)
+	insertPlayerSQL := `INSERT INTO players(name, game_id, is_admin, token, token_expires_at) VALUES(?, ?, ?, ?, ?);`
+	_, err = txn.Exec(insertPlayerSQL, player, gameId, true, hashToken(token.Value), token.ExpiresAt.Unix())
 	if err != nil {
 		s.Logger.Error("Failed to save player", err)
 		errRoll := txn.Rollback()
@@ -136,14 +227,14 @@ func (s *SqliteStore) CreateNewGame(gameId, player, token string, maxPlayers, to
 	return nil
 }
 
-func (s *SqliteStore) AddPlayerToGame(gameId, playerName, token string) error {
+func (s *SqliteStore) AddPlayerToGame(gameId, playerName string, token SessionToken) error {
 	txn, err := s.Conn.Beginx()
 	if err != nil {
 		s.Logger.Error("Failed to add player to game", err)
 		return err
 	}
-	insertPlayerSQL := `INSERT INTO players(name, game_id, token) VALUES(?, ?, ?);`
-	_, err = txn.Exec(insertPlayerSQL, playerName, gameId, token)
+	insertPlayerSQL := `INSERT INTO players(name, game_id, token, token_expires_at) VALUES(?, ?, ?, ?);`
+	_, err = txn.Exec(insertPlayerSQL, playerName, gameId, hashToken(token.Value), token.ExpiresAt.Unix())
 	if err != nil {
 		s.Logger.Error("Failed to add player to game", err)
 		errRoll := txn.Rollback()
@@ -174,6 +265,147 @@ func (s *SqliteStore) AddPlayerToGame(gameId, playerName, token string) error {
 	return nil
 }
 
-func (s *SqliteStore) UpdatePlayerScore(gameId, playerName string, scoreDelta uint8) error {
+// SetAdmin makes playerName the only admin of the game
+func (s *SqliteStore) SetAdmin(gameId, playerName string) error {
+	sql := `UPDATE players SET is_admin = (name = ?)
+	WHERE game_id = ? AND EXISTS (SELECT 1 FROM players WHERE game_id = ? AND name = ?);`
+	result, err := s.Conn.Exec(sql, playerName, gameId, gameId, playerName)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to make player %s admin", playerName), err)
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+		err = fmt.Errorf("Player %s not found in game %s", playerName, gameId)
+		s.Logger.Error(fmt.Sprintf("Failed to make player %s admin", playerName), err)
+		return err
+	}
 	return nil
 }
+
+func (s *SqliteStore) UpdatePlayerScore(gameId, playerName string, scoreDelta uint) error {
+	sql := `INSERT INTO scores(game_id, player, score) VALUES(?, ?, ?)
+	ON CONFLICT(game_id, player) DO UPDATE SET score = score + excluded.score;`
+	_, err := s.Conn.Exec(sql, gameId, playerName, scoreDelta)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to update score of player %s", playerName), err)
+		return err
+	}
+	return nil
+}
+
+// GetGameScores ranks every player of the game by score, highest first. Players
+// who are yet to score are included with 0 points
+func (s *SqliteStore) GetGameScores(gameId string) ([]Score, error) {
+	scores := []Score{}
+	sql := `SELECT players.name AS player, COALESCE(scores.score, 0) AS score FROM players
+	LEFT JOIN scores ON scores.game_id = players.game_id AND scores.player = players.name
+	WHERE players.game_id = ?
+	ORDER BY score DESC, players.name ASC;`
+	err := s.Conn.Select(&scores, sql, gameId)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to fetch scores of game %s", gameId), err)
+		return nil, err
+	}
+	return scores, nil
+}
+
+func (s *SqliteStore) ResetScores(gameId string) error {
+	_, err := s.Conn.Exec(`DELETE FROM scores WHERE game_id = ?;`, gameId)
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to reset scores of game %s", gameId), err)
+		return err
+	}
+	return nil
+}
+
+func (s *SqliteStore) DeleteGame(gameId string) error {
+	txn, err := s.Conn.Beginx()
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to delete game %s", gameId), err)
+		return err
+	}
+	// Foreign keys are off by default in sqlite, so nothing cascades
+	for _, deleteSQL := range []string{
+		`DELETE FROM game_snapshots WHERE game_id = ?;`,
+		`DELETE FROM scores WHERE game_id = ?;`,
+		`DELETE FROM players WHERE game_id = ?;`,
+		`DELETE FROM games WHERE game_id = ?;`,
+	} {
+		if _, err := txn.Exec(deleteSQL, gameId); err != nil {
+			s.Logger.Error(fmt.Sprintf("Failed to delete game %s", gameId), err)
+			errRoll := txn.Rollback()
+			if errRoll != nil {
+				s.Logger.Error("Failed to rollback DeleteGame txn", errRoll)
+				return errRoll
+			}
+			return err
+		}
+	}
+	errCommit := txn.Commit()
+	if errCommit != nil {
+		s.Logger.Error("Failed to Commit DeleteGame txn", errCommit)
+		return errCommit
+	}
+	s.Logger.Info(fmt.Sprintf("Game %s deleted", gameId))
+	return nil
+}
+
+func (s *SqliteStore) SaveSnapshot(gameId string, snapshot []byte, savedAt time.Time) error {
+	saveSnapshotSQL := `INSERT INTO game_snapshots(game_id, snapshot, updated_at) VALUES(?, ?, ?)
+	ON CONFLICT(game_id) DO UPDATE SET snapshot = excluded.snapshot, updated_at = excluded.updated_at;`
+	_, err := s.Conn.Exec(saveSnapshotSQL, gameId, string(snapshot), savedAt.Unix())
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to save snapshot of game %s", gameId), err)
+		return err
+	}
+	return nil
+}
+
+func (s *SqliteStore) GetSnapshots() ([]GameSnapshot, error) {
+	snapshots := []GameSnapshot{}
+	err := s.Conn.Select(&snapshots, `SELECT * FROM game_snapshots;`)
+	if err != nil {
+		s.Logger.Error("Failed to read game snapshots", err)
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *SqliteStore) AddWords(words []string) error {
+	txn, err := s.Conn.Beginx()
+	if err != nil {
+		s.Logger.Error("Failed to add words", err)
+		return err
+	}
+	insertWordSQL := `INSERT OR IGNORE INTO words(word) VALUES(?);`
+	for _, word := range words {
+		_, err = txn.Exec(insertWordSQL, word)
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("Failed to add word %s", word), err)
+			errRoll := txn.Rollback()
+			if errRoll != nil {
+				s.Logger.Error("Failed to rollback AddWords txn", errRoll)
+				return errRoll
+			}
+			return err
+		}
+	}
+	errCommit := txn.Commit()
+	if errCommit != nil {
+		s.Logger.Error("Failed to Commit AddWords txn", errCommit)
+		return errCommit
+	}
+	s.Logger.Info(fmt.Sprintf("Added %d words to the word bank", len(words)))
+	return nil
+}
+
+func (s *SqliteStore) GetRandomWords(count uint8) ([]string, error) {
+	words := []string{}
+	sql := `SELECT word FROM words ORDER BY RANDOM() LIMIT ?;`
+	err := s.Conn.Select(&words, sql, count)
+	if err != nil {
+		s.Logger.Error("Failed to fetch random words", err)
+		return nil, err
+	}
+	return words, nil
+}
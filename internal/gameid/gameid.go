@@ -0,0 +1,203 @@
+package gameid
+
+import (
+	crypto "crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Styles of game id an Allocator can produce
+const (
+	// STYLE_RANDOM ids are Length characters drawn from Alphabet, e.g. "qzkxrp"
+	STYLE_RANDOM = "random"
+	// STYLE_FRIENDLY ids are easy to read out loud, e.g. "brave-otter-42"
+	STYLE_FRIENDLY = "friendly"
+)
+
+const DEFAULT_LENGTH = 6
+const MIN_LENGTH = 4
+const MAX_LENGTH = 32
+const DEFAULT_ALPHABET = "abcdefghijklmnopqrstuvwxyz"
+
+// DEFAULT_MAX_ATTEMPTS bounds how many taken ids are skipped before giving up
+const DEFAULT_MAX_ATTEMPTS = 10
+
+// ALLOWED_CHARACTERS keeps ids URL safe and unambiguous when read out loud
+const ALLOWED_CHARACTERS = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+var DEFAULT_CONFIG = Config{
+	Style:       STYLE_RANDOM,
+	Length:      DEFAULT_LENGTH,
+	Alphabet:    DEFAULT_ALPHABET,
+	MaxAttempts: DEFAULT_MAX_ATTEMPTS,
+}
+
+// blockedWords never appear in an id, even spanning the words of a friendly one
+var blockedWords = []string{
+	"anal", "anus", "arse", "ass", "bitch", "boob", "butt", "cock", "coon", "crap", "cum", "cunt",
+	"damn", "dick", "dyke", "fag", "fuck", "gay", "hell", "homo", "jizz", "kike", "kkk", "nazi",
+	"nig", "paki", "penis", "piss", "poo", "porn", "pussy", "rape", "sex", "shit", "slut", "spic",
+	"tit", "twat", "wank", "whore",
+}
+
+var adjectives = []string{
+	"amber", "brave", "bright", "calm", "clever", "cosy", "curly", "dizzy", "eager", "fancy",
+	"fluffy", "gentle", "giant", "golden", "happy", "jolly", "kind", "lively", "lucky", "merry",
+	"mighty", "misty", "noble", "proud", "quick", "quiet", "rapid", "rosy", "shiny", "silly",
+	"sleepy", "smart", "snowy", "sunny", "swift", "tidy", "tiny", "witty", "young", "zesty",
+}
+
+var nouns = []string{
+	"badger", "bear", "beaver", "bison", "camel", "cobra", "crane", "dingo", "dolphin", "eagle",
+	"falcon", "ferret", "gecko", "heron", "hippo", "koala", "lemur", "llama", "lynx", "moose",
+	"narwhal", "newt", "otter", "owl", "panda", "parrot", "pelican", "penguin", "puffin", "rabbit",
+	"raccoon", "salmon", "seal", "sloth", "swan", "tiger", "toucan", "turtle", "walrus", "zebra",
+}
+
+type Config struct {
+	// Style is STYLE_RANDOM or STYLE_FRIENDLY
+	Style string
+	// Length and Alphabet only apply to STYLE_RANDOM
+	Length   int
+	Alphabet string
+	// MaxAttempts is how many candidates are tried before Allocate gives up
+	MaxAttempts int
+}
+
+// Allocator hands out game ids no other game is using
+type Allocator struct {
+	config   Config
+	alphabet []byte
+}
+
+// New validates config, filling in defaults for the fields left empty
+func New(config Config) (*Allocator, error) {
+	if len(config.Style) == 0 {
+		config.Style = DEFAULT_CONFIG.Style
+	}
+	if config.Length == 0 {
+		config.Length = DEFAULT_CONFIG.Length
+	}
+	if len(config.Alphabet) == 0 {
+		config.Alphabet = DEFAULT_CONFIG.Alphabet
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = DEFAULT_CONFIG.MaxAttempts
+	}
+	if config.Style != STYLE_RANDOM && config.Style != STYLE_FRIENDLY {
+		return nil, fmt.Errorf("Unknown game id style %q", config.Style)
+	}
+	if config.Length < MIN_LENGTH || config.Length > MAX_LENGTH {
+		return nil, fmt.Errorf("Game id length must be between %d and %d", MIN_LENGTH, MAX_LENGTH)
+	}
+	if config.MaxAttempts < 0 {
+		return nil, fmt.Errorf("Game id attempts must be positive")
+	}
+	alphabet := []byte{}
+	for _, c := range []byte(strings.ToLower(config.Alphabet)) {
+		if !strings.ContainsRune(ALLOWED_CHARACTERS, rune(c)) {
+			return nil, fmt.Errorf("Game id alphabet may only contain characters from %q", ALLOWED_CHARACTERS)
+		}
+		if !strings.ContainsRune(string(alphabet), rune(c)) {
+			alphabet = append(alphabet, c)
+		}
+	}
+	if len(alphabet) < 2 {
+		return nil, fmt.Errorf("Game id alphabet needs at least 2 distinct characters")
+	}
+	return &Allocator{config: config, alphabet: alphabet}, nil
+}
+
+// ErrTaken is returned by the claim function passed to Claim for ids already in use
+var ErrTaken = errors.New("Game id is already taken")
+
+// Allocate returns an id for which taken reports false. taken is asked about
+// every candidate, so it should look wherever games live. Candidates spelling
+// something offensive are thrown away and count as attempts too
+func (a *Allocator) Allocate(taken func(gameId string) bool) (string, error) {
+	return a.Claim(func(gameId string) error {
+		if taken(gameId) {
+			return ErrTaken
+		}
+		return nil
+	})
+}
+
+// Claim hands candidates over to claim until it takes one, for callers that
+// only find out an id is in use as they try to save it. claim returns ErrTaken
+// to be handed the next candidate, any other error is returned right away
+func (a *Allocator) Claim(claim func(gameId string) error) (string, error) {
+	for attempt := 0; attempt < a.config.MaxAttempts; attempt++ {
+		gameId, err := a.candidate()
+		if err != nil {
+			return "", err
+		}
+		if isOffensive(gameId) {
+			continue
+		}
+		err = claim(gameId)
+		if errors.Is(err, ErrTaken) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return gameId, nil
+	}
+	return "", fmt.Errorf("Failed to allocate a unique game id after %d attempts", a.config.MaxAttempts)
+}
+
+func (a *Allocator) candidate() (string, error) {
+	if a.config.Style == STYLE_FRIENDLY {
+		return a.friendly()
+	}
+	return a.random()
+}
+
+func (a *Allocator) random() (string, error) {
+	id := make([]byte, a.config.Length)
+	for i := range id {
+		n, err := randomInt(len(a.alphabet))
+		if err != nil {
+			return "", err
+		}
+		id[i] = a.alphabet[n]
+	}
+	return string(id), nil
+}
+
+func (a *Allocator) friendly() (string, error) {
+	adjective, err := randomInt(len(adjectives))
+	if err != nil {
+		return "", err
+	}
+	noun, err := randomInt(len(nouns))
+	if err != nil {
+		return "", err
+	}
+	number, err := randomInt(90)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%d", adjectives[adjective], nouns[noun], number+10), nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := crypto.Int(crypto.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func isOffensive(gameId string) bool {
+	id := strings.ReplaceAll(gameId, "-", "")
+	for _, word := range blockedWords {
+		if strings.Contains(id, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package gameid
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomIds(t *testing.T) {
+	allocator, err := New(Config{Length: 8, Alphabet: "abc123"})
+	require.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		gameId, err := allocator.Allocate(func(string) bool { return false })
+		require.Nil(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[abc123]{8}$`), gameId)
+	}
+}
+
+func TestDefaultIds(t *testing.T) {
+	allocator, err := New(Config{})
+	require.Nil(t, err)
+	gameId, err := allocator.Allocate(func(string) bool { return false })
+	require.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-z]{6}$`), gameId)
+}
+
+func TestFriendlyIds(t *testing.T) {
+	allocator, err := New(Config{Style: STYLE_FRIENDLY})
+	require.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		gameId, err := allocator.Allocate(func(string) bool { return false })
+		require.Nil(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[a-z]+-[a-z]+-[1-9][0-9]$`), gameId)
+		assert.False(t, isOffensive(gameId))
+	}
+}
+
+func TestAllocateSkipsTakenIds(t *testing.T) {
+	allocator, err := New(Config{Length: 4, Alphabet: "xy"})
+	require.Nil(t, err)
+	asked := []string{}
+	gameId, err := allocator.Allocate(func(gameId string) bool {
+		asked = append(asked, gameId)
+		return len(asked) < 3
+	})
+	require.Nil(t, err)
+	assert.Len(t, asked, 3)
+	assert.Equal(t, asked[2], gameId)
+}
+
+func TestAllocateGivesUp(t *testing.T) {
+	allocator, err := New(Config{MaxAttempts: 3})
+	require.Nil(t, err)
+	asked := 0
+	_, err = allocator.Allocate(func(string) bool {
+		asked++
+		return true
+	})
+	assert.NotNil(t, err)
+	assert.LessOrEqual(t, asked, 3)
+}
+
+func TestClaim(t *testing.T) {
+	allocator, err := New(Config{MaxAttempts: 3})
+	require.Nil(t, err)
+	claimed := []string{}
+	_, err = allocator.Claim(func(gameId string) error {
+		claimed = append(claimed, gameId)
+		return ErrTaken
+	})
+	assert.NotNil(t, err)
+	assert.LessOrEqual(t, len(claimed), 3, "Taken ids count against the configured attempts")
+
+	failure := errors.New("database is down")
+	claimed = []string{}
+	_, err = allocator.Claim(func(gameId string) error {
+		claimed = append(claimed, gameId)
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Len(t, claimed, 1, "Other errors are not retried")
+}
+
+func TestOffensiveIdsSkipped(t *testing.T) {
+	// Plenty of the ids this alphabet spells contain "ass"
+	allocator, err := New(Config{Length: 4, Alphabet: "as", MaxAttempts: 1000})
+	require.Nil(t, err)
+	for i := 0; i < 50; i++ {
+		gameId, err := allocator.Allocate(func(string) bool { return false })
+		require.Nil(t, err)
+		assert.NotContains(t, gameId, "ass")
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		description string
+		config      Config
+	}{
+		{"Test unknown style", Config{Style: "emoji"}},
+		{"Test too short", Config{Length: MIN_LENGTH - 1}},
+		{"Test too long", Config{Length: MAX_LENGTH + 1}},
+		{"Test alphabet with unsafe characters", Config{Alphabet: "ab/"}},
+		{"Test alphabet with a single character", Config{Alphabet: "aaaa"}},
+		{"Test negative attempts", Config{MaxAttempts: -1}},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := New(test.config)
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -2,12 +2,21 @@ package parser
 
 import (
 	"encoding/json"
+	"time"
 )
 
 type CreateGameRequest struct {
-	Player         string `json:"player,omitempty"`
-	MaxPlayerCount uint8  `json:"max_players,omitempty"`
-	TotalRounds    uint8  `json:"total_rounds,omitempty"`
+	Player         string        `json:"player,omitempty"`
+	MaxPlayerCount uint8         `json:"max_players,omitempty"`
+	TotalRounds    uint8         `json:"total_rounds,omitempty"`
+	Hints          *uint8        `json:"hints,omitempty"`
+	Scoring        *ScoringRules `json:"scoring,omitempty"`
+}
+
+type ScoringRules struct {
+	GuessMaxPoints uint8 `json:"guess_max_points"`
+	GuessMinPoints uint8 `json:"guess_min_points"`
+	DrawerPoints   uint8 `json:"drawer_points"`
 }
 
 func ParseCreateGameRequest(data []byte) (*CreateGameRequest, error) {
@@ -19,8 +28,12 @@ func ParseCreateGameRequest(data []byte) (*CreateGameRequest, error) {
 	return gameRequest, err
 }
 
+// Token is only sent back when the server accepts bearer tokens, clients pass it
+// in an Authorization header or as a websocket subprotocol
 type CreateGameResponse struct {
-	GameId string `json:"game_id,omitempty"`
+	GameId         string     `json:"game_id,omitempty"`
+	Token          string     `json:"token,omitempty"`
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
 }
 
 type JoinGameRequest struct {
@@ -28,7 +41,9 @@ type JoinGameRequest struct {
 }
 
 type JoinGameResponse struct {
-	GameUrl string `json:"game_url,omitempty"`
+	GameUrl        string     `json:"game_url,omitempty"`
+	Token          string     `json:"token,omitempty"`
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
 }
 
 func ParseJoinGameRequest(data []byte) (*JoinGameRequest, error) {
@@ -39,8 +54,3 @@ func ParseJoinGameRequest(data []byte) (*JoinGameRequest, error) {
 	}
 	return request, err
 }
-
-type GamePlayerInput struct {
-	Xcoord uint8 `json:"x_cord,omitempty"`
-	Ycoord uint8 `json:"y_cord,omitempty"`
-}
@@ -0,0 +1,476 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// PROTOCOL_VERSION is bumped whenever a message changes in a way older clients can't handle
+const PROTOCOL_VERSION = 1
+
+// Every frame exchanged over a game's websocket is an Envelope. Type decides
+// what Payload holds, Seq orders the frames sent by either side
+type Envelope struct {
+	Version uint8           `json:"version"`
+	Type    string          `json:"type"`
+	Seq     uint64          `json:"seq"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Messages sent by players
+const (
+	MSG_STROKE      = "stroke"
+	MSG_CHAT        = "chat"
+	MSG_CHOOSE_WORD = "choose_word"
+	MSG_UNDO        = "undo"
+	MSG_REDO        = "redo"
+	MSG_CLEAR       = "clear_canvas"
+	MSG_VOTE_KICK   = "vote_kick"
+)
+
+// Messages sent by the server
+const (
+	MSG_CANVAS_SNAPSHOT = "canvas_snapshot"
+	MSG_LOBBY           = "lobby"
+	MSG_PLAYER_JOINED   = "player_joined"
+	MSG_PLAYER_LEFT     = "player_left"
+	MSG_ADMIN_CHANGED   = "admin_changed"
+	MSG_KICK_VOTE       = "kick_vote"
+	MSG_KICK_VOTE_ENDED = "kick_vote_ended"
+	MSG_CHOOSING_WORD   = "choosing_word"
+	MSG_WORD_CHOICES    = "word_choices"
+	MSG_WORD_SELECTED   = "word_selected"
+	MSG_TURN_START      = "turn_start"
+	MSG_HINT            = "hint"
+	MSG_TURN_END        = "turn_end"
+	MSG_ROUND_END       = "round_end"
+	MSG_GAME_OVER       = "game_over"
+	MSG_REMATCH         = "rematch"
+	MSG_CORRECT_GUESS   = "correct_guess"
+	MSG_CLOSE_GUESS     = "close_guess"
+	MSG_SCORE_UPDATE    = "score_update"
+	MSG_ERROR           = "error"
+)
+
+// playerMessages maps the message types players may send to their payload
+var playerMessages = map[string]func() any{
+	MSG_STROKE:      func() any { return &Stroke{} },
+	MSG_CHAT:        func() any { return &ChatInput{} },
+	MSG_CHOOSE_WORD: func() any { return &ChooseWordInput{} },
+	MSG_UNDO:        func() any { return &UndoInput{} },
+	MSG_REDO:        func() any { return &RedoInput{} },
+	MSG_CLEAR:       func() any { return &ClearCanvasInput{} },
+	MSG_VOTE_KICK:   func() any { return &VoteKickInput{} },
+}
+
+// serverMessages maps the message types the server sends to their payload, the
+// drawing and chat messages players send are relayed back as events
+var serverMessages = map[string]func() any{
+	MSG_STROKE:          func() any { return &StrokeEvent{} },
+	MSG_CHAT:            func() any { return &ChatEvent{} },
+	MSG_UNDO:            func() any { return &UndoEvent{} },
+	MSG_REDO:            func() any { return &StrokeEvent{} },
+	MSG_CLEAR:           func() any { return &ClearCanvasEvent{} },
+	MSG_CANVAS_SNAPSHOT: func() any { return &CanvasSnapshotEvent{} },
+	MSG_LOBBY:           func() any { return &Lobby{} },
+	MSG_PLAYER_JOINED:   func() any { return &PlayerJoinedEvent{} },
+	MSG_PLAYER_LEFT:     func() any { return &PlayerLeftEvent{} },
+	MSG_ADMIN_CHANGED:   func() any { return &AdminChangedEvent{} },
+	MSG_KICK_VOTE:       func() any { return &KickVoteEvent{} },
+	MSG_KICK_VOTE_ENDED: func() any { return &KickVoteEndedEvent{} },
+	MSG_CHOOSING_WORD:   func() any { return &ChoosingWordEvent{} },
+	MSG_WORD_CHOICES:    func() any { return &WordChoicesEvent{} },
+	MSG_WORD_SELECTED:   func() any { return &WordSelectedEvent{} },
+	MSG_TURN_START:      func() any { return &TurnStartEvent{} },
+	MSG_HINT:            func() any { return &HintEvent{} },
+	MSG_TURN_END:        func() any { return &TurnEndEvent{} },
+	MSG_ROUND_END:       func() any { return &RoundEndEvent{} },
+	MSG_GAME_OVER:       func() any { return &GameOverEvent{} },
+	MSG_REMATCH:         func() any { return &RematchEvent{} },
+	MSG_CORRECT_GUESS:   func() any { return &CorrectGuessEvent{} },
+	MSG_CLOSE_GUESS:     func() any { return &CloseGuessEvent{} },
+	MSG_SCORE_UPDATE:    func() any { return &ScoreUpdateEvent{} },
+	MSG_ERROR:           func() any { return &ErrorEvent{} },
+}
+
+const (
+	ERR_MALFORMED           = "malformed"
+	ERR_UNSUPPORTED_VERSION = "unsupported_version"
+	ERR_UNKNOWN_TYPE        = "unknown_type"
+	ERR_INVALID_PAYLOAD     = "invalid_payload"
+	ERR_REJECTED            = "rejected"
+)
+
+// ProtocolError is reported back to the player whose message could not be handled
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func NewProtocolError(code, format string, args ...any) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Encode wraps payload in an Envelope of the current protocol version
+func Encode(msgType string, seq uint64, payload any) ([]byte, error) {
+	var raw json.RawMessage
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+	return json.Marshal(Envelope{Version: PROTOCOL_VERSION, Type: msgType, Seq: seq, Payload: raw})
+}
+
+// Decode parses an Envelope without looking at its payload
+func Decode(data []byte) (*Envelope, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, NewProtocolError(ERR_MALFORMED, "frame is not a valid envelope")
+	}
+	if envelope.Version != PROTOCOL_VERSION {
+		return envelope, NewProtocolError(ERR_UNSUPPORTED_VERSION, "protocol version %d is not supported", envelope.Version)
+	}
+	return envelope, nil
+}
+
+// DecodePayload strictly unmarshals the envelope's payload into target
+func DecodePayload(envelope *Envelope, target any) error {
+	decoder := json.NewDecoder(bytes.NewReader(envelope.Payload))
+	decoder.DisallowUnknownFields()
+	if len(envelope.Payload) == 0 || decoder.Decode(target) != nil {
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "invalid payload for message type %q", envelope.Type)
+	}
+	return nil
+}
+
+// DecodePlayerMessage parses a frame sent by a player and returns its envelope
+// along with a pointer to the typed payload. Unknown types and payloads that
+// don't match their type are rejected with a *ProtocolError
+func DecodePlayerMessage(data []byte) (*Envelope, any, error) {
+	envelope, err := Decode(data)
+	if err != nil {
+		return envelope, nil, err
+	}
+	newPayload, known := playerMessages[envelope.Type]
+	if !known {
+		return envelope, nil, NewProtocolError(ERR_UNKNOWN_TYPE, "unknown message type %q", envelope.Type)
+	}
+	payload := newPayload()
+	if err := DecodePayload(envelope, payload); err != nil {
+		return envelope, nil, err
+	}
+	if v, ok := payload.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return envelope, nil, err
+		}
+	}
+	return envelope, payload, nil
+}
+
+// DecodeServerMessage parses a frame sent by the server and returns its
+// envelope along with a pointer to the typed payload. Unlike player messages,
+// fields a newer server added are ignored rather than rejected
+func DecodeServerMessage(data []byte) (*Envelope, any, error) {
+	envelope, err := Decode(data)
+	if err != nil {
+		return envelope, nil, err
+	}
+	newPayload, known := serverMessages[envelope.Type]
+	if !known {
+		return envelope, nil, NewProtocolError(ERR_UNKNOWN_TYPE, "unknown message type %q", envelope.Type)
+	}
+	payload := newPayload()
+	if len(envelope.Payload) == 0 || json.Unmarshal(envelope.Payload, payload) != nil {
+		return envelope, nil, NewProtocolError(ERR_INVALID_PAYLOAD, "invalid payload for message type %q", envelope.Type)
+	}
+	return envelope, payload, nil
+}
+
+// validator is implemented by payloads that need more checks than their json shape
+type validator interface {
+	Validate() error
+}
+
+const (
+	TOOL_PEN    = "pen"
+	TOOL_ERASER = "eraser"
+	TOOL_FILL   = "fill"
+)
+
+const MAX_CANVAS_SIZE = 4096
+const MAX_BRUSH_SIZE = 64
+const MAX_STROKE_POINTS = 512
+
+// MAX_MESSAGE_SIZE is how many bytes a player's message may take, larger
+// frames are dropped before they are read. A stroke batch of MAX_STROKE_POINTS
+// is the largest valid input, the limit leaves room for whitespace
+const MAX_MESSAGE_SIZE = 32 * MAX_STROKE_POINTS
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Point struct {
+	X uint16 `json:"x"`
+	Y uint16 `json:"y"`
+}
+
+// Canvas is the size of the drawer's canvas, clients scale points to their own canvas
+type Canvas struct {
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
+
+// Stroke is a batch of points drawn with the same tool. Long strokes are streamed
+// as several batches sharing a StrokeId, a fill is a single point
+type Stroke struct {
+	StrokeId  uint32  `json:"stroke_id"`
+	Tool      string  `json:"tool"`
+	Color     string  `json:"color"`
+	BrushSize uint8   `json:"brush_size"`
+	Canvas    Canvas  `json:"canvas"`
+	Points    []Point `json:"points"`
+}
+
+func (s *Stroke) Validate() error {
+	if s.StrokeId == 0 {
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "stroke_id is required")
+	}
+	switch s.Tool {
+	case TOOL_PEN, TOOL_ERASER:
+		if len(s.Points) == 0 || len(s.Points) > MAX_STROKE_POINTS {
+			return NewProtocolError(ERR_INVALID_PAYLOAD, "a stroke carries between 1 and %d points", MAX_STROKE_POINTS)
+		}
+	case TOOL_FILL:
+		if len(s.Points) != 1 {
+			return NewProtocolError(ERR_INVALID_PAYLOAD, "a fill carries exactly one point")
+		}
+	default:
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "unknown tool %q", s.Tool)
+	}
+	if !colorPattern.MatchString(s.Color) {
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "color must look like #rrggbb")
+	}
+	if s.Tool != TOOL_FILL && (s.BrushSize == 0 || s.BrushSize > MAX_BRUSH_SIZE) {
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "brush_size must be between 1 and %d", MAX_BRUSH_SIZE)
+	}
+	if s.Canvas.Width == 0 || s.Canvas.Height == 0 || s.Canvas.Width > MAX_CANVAS_SIZE || s.Canvas.Height > MAX_CANVAS_SIZE {
+		return NewProtocolError(ERR_INVALID_PAYLOAD, "canvas must be between 1x1 and %dx%d", MAX_CANVAS_SIZE, MAX_CANVAS_SIZE)
+	}
+	for _, point := range s.Points {
+		if point.X >= s.Canvas.Width || point.Y >= s.Canvas.Height {
+			return NewProtocolError(ERR_INVALID_PAYLOAD, "point (%d, %d) lies outside the canvas", point.X, point.Y)
+		}
+	}
+	return nil
+}
+
+type ChatInput struct {
+	Text string `json:"text"`
+}
+
+type ChooseWordInput struct {
+	Word string `json:"word"`
+}
+
+// VoteKickInput starts a vote to kick Target out of the game, or backs the vote
+// already running against them
+type VoteKickInput struct {
+	Target string `json:"target"`
+}
+
+// UndoInput, RedoInput and ClearCanvasInput are sent by the drawer with an empty payload
+type UndoInput struct{}
+
+type RedoInput struct{}
+
+type ClearCanvasInput struct{}
+
+// StrokeEvent relays a stroke from the drawer to everyone else, it is also
+// the payload of a redo since the redone stroke has to be drawn again
+type StrokeEvent struct {
+	Drawer string `json:"drawer"`
+	Stroke
+}
+
+// CanvasSnapshotEvent is the first message a player receives after connecting,
+// it holds everything needed to catch up with the ongoing turn. Word is only
+// filled in for the drawer
+type CanvasSnapshotEvent struct {
+	Round       uint8      `json:"round"`
+	TotalRounds uint8      `json:"total_rounds"`
+	Drawer      string     `json:"drawer,omitempty"`
+	Word        string     `json:"word,omitempty"`
+	WordLength  int        `json:"word_length,omitempty"`
+	Hint        string     `json:"hint,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Strokes     []Stroke   `json:"strokes"`
+}
+
+// Reasons for a player leaving the game
+const (
+	LEFT_DISCONNECTED = "disconnected"
+	LEFT_QUIT         = "quit"
+	LEFT_KICKED       = "kicked"
+	LEFT_TIMED_OUT    = "timed_out"
+)
+
+type LobbyPlayer struct {
+	Name      string `json:"name"`
+	IsAdmin   bool   `json:"is_admin"`
+	Connected bool   `json:"connected"`
+}
+
+type GameSettings struct {
+	MaxPlayers  uint8        `json:"max_players"`
+	TotalRounds uint8        `json:"total_rounds"`
+	Hints       uint8        `json:"hints"`
+	Scoring     ScoringRules `json:"scoring"`
+}
+
+// Lobby is the waiting room as it stands, every lobby event carries the whole
+// of it so that clients can simply re-render
+type Lobby struct {
+	Players  []LobbyPlayer `json:"players"`
+	Settings GameSettings  `json:"settings"`
+}
+
+type PlayerJoinedEvent struct {
+	Player string `json:"player"`
+	Lobby  Lobby  `json:"lobby"`
+}
+
+type PlayerLeftEvent struct {
+	Player string `json:"player"`
+	Reason string `json:"reason"`
+	Lobby  Lobby  `json:"lobby"`
+}
+
+type AdminChangedEvent struct {
+	Admin string `json:"admin"`
+	Lobby Lobby  `json:"lobby"`
+}
+
+// KickVoteEvent is sent whenever someone votes to kick Target, the vote passes
+// once Needed players have voted
+type KickVoteEvent struct {
+	Target string    `json:"target"`
+	Voters []string  `json:"voters"`
+	Needed int       `json:"needed"`
+	EndsAt time.Time `json:"ends_at"`
+}
+
+type KickVoteEndedEvent struct {
+	Target string `json:"target"`
+	Kicked bool   `json:"kicked"`
+}
+
+// UndoEvent tells players to erase the stroke with StrokeId
+type UndoEvent struct {
+	Drawer   string `json:"drawer"`
+	StrokeId uint32 `json:"stroke_id"`
+}
+
+type ClearCanvasEvent struct {
+	Drawer string `json:"drawer"`
+}
+
+type ChoosingWordEvent struct {
+	Drawer   string    `json:"drawer"`
+	Round    uint8     `json:"round"`
+	ChooseBy time.Time `json:"choose_by"`
+}
+
+// WordChoicesEvent is only ever sent to the drawer
+type WordChoicesEvent struct {
+	Words    []string  `json:"words"`
+	ChooseBy time.Time `json:"choose_by"`
+}
+
+// WordSelectedEvent is only ever sent to the drawer
+type WordSelectedEvent struct {
+	Word       string `json:"word"`
+	AutoPicked bool   `json:"auto_picked"`
+}
+
+type TurnStartEvent struct {
+	Drawer      string    `json:"drawer"`
+	Round       uint8     `json:"round"`
+	TotalRounds uint8     `json:"total_rounds"`
+	WordLength  int       `json:"word_length"`
+	Hint        string    `json:"hint"`
+	EndsAt      time.Time `json:"ends_at"`
+}
+
+// HintEvent reveals some letters of the word to guessers, hidden letters are
+// shown as underscores, e.g. "__a__"
+type HintEvent struct {
+	Hint string `json:"hint"`
+}
+
+type TurnEndEvent struct {
+	Drawer string `json:"drawer"`
+	Round  uint8  `json:"round"`
+	Word   string `json:"word"`
+}
+
+// Standing is a player's place on the scoreboard, players with the same score share a Rank
+type Standing struct {
+	Rank   int    `json:"rank"`
+	Player string `json:"player"`
+	Score  uint   `json:"score"`
+}
+
+// RoundEndEvent is sent in the pause between two rounds
+type RoundEndEvent struct {
+	Round       uint8      `json:"round"`
+	TotalRounds uint8      `json:"total_rounds"`
+	Standings   []Standing `json:"standings"`
+}
+
+// GameOverEvent carries the final scoreboard, best player first
+type GameOverEvent struct {
+	RoundsPlayed uint8      `json:"rounds_played"`
+	Standings    []Standing `json:"standings"`
+}
+
+// RematchEvent tells players the game is back in the lobby, waiting for the admin to start it again
+type RematchEvent struct {
+	TotalRounds uint8 `json:"total_rounds"`
+}
+
+type ChatEvent struct {
+	Player string `json:"player"`
+	Text   string `json:"text"`
+}
+
+type CorrectGuessEvent struct {
+	Player string `json:"player"`
+}
+
+// CloseGuessEvent is only ever sent to the player who made the guess
+type CloseGuessEvent struct {
+	Guess string `json:"guess"`
+}
+
+// ScoreUpdateEvent carries the points each player earned in the turn that just
+// ended along with everyone's running total
+type ScoreUpdateEvent struct {
+	Deltas map[string]uint `json:"deltas"`
+	Scores map[string]uint `json:"scores"`
+}
+
+// ErrorEvent tells a player why the message with sequence number InReplyTo was rejected
+type ErrorEvent struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	InReplyTo uint64 `json:"in_reply_to"`
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePlayerMessage(t *testing.T) {
+	tests := []struct {
+		description     string
+		frame           string
+		expectedPayload any
+		expectedErrCode string
+	}{
+		{"Test chat message", `{"version":1,"type":"chat","seq":3,"payload":{"text":"hello"}}`, &ChatInput{Text: "hello"}, ""},
+		{"Test word choice", `{"version":1,"type":"choose_word","seq":4,"payload":{"word":"apple"}}`, &ChooseWordInput{Word: "apple"}, ""},
+		{"Test vote to kick", `{"version":1,"type":"vote_kick","seq":5,"payload":{"target":"bob"}}`, &VoteKickInput{Target: "bob"}, ""},
+		{"Test frame that isn't json", `not json`, nil, ERR_MALFORMED},
+		{"Test unsupported protocol version", `{"version":9,"type":"chat","payload":{"text":"hello"}}`, nil, ERR_UNSUPPORTED_VERSION},
+		{"Test unknown message type", `{"version":1,"type":"dance","payload":{}}`, nil, ERR_UNKNOWN_TYPE},
+		{"Test server message sent by a player", `{"version":1,"type":"turn_start","payload":{}}`, nil, ERR_UNKNOWN_TYPE},
+		{"Test missing payload", `{"version":1,"type":"chat"}`, nil, ERR_INVALID_PAYLOAD},
+		{"Test payload of the wrong shape", `{"version":1,"type":"chat","payload":{"text":42}}`, nil, ERR_INVALID_PAYLOAD},
+		{"Test payload with unknown fields", `{"version":1,"type":"chat","payload":{"txt":"hello"}}`, nil, ERR_INVALID_PAYLOAD},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			_, payload, err := DecodePlayerMessage([]byte(tc.frame))
+			if len(tc.expectedErrCode) == 0 {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedPayload, payload)
+				return
+			}
+			protocolErr, ok := err.(*ProtocolError)
+			assert.True(t, ok, "Expected a ProtocolError")
+			assert.Equal(t, tc.expectedErrCode, protocolErr.Code)
+		})
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	data, err := Encode(MSG_CHAT, 7, ChatInput{Text: "hello"})
+	assert.Nil(t, err)
+	envelope, payload, err := DecodePlayerMessage(data)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), envelope.Seq)
+	assert.Equal(t, &ChatInput{Text: "hello"}, payload)
+}
+
+func TestStrokeValidate(t *testing.T) {
+	valid := func() Stroke {
+		return Stroke{
+			StrokeId:  1,
+			Tool:      TOOL_PEN,
+			Color:     "#1a2B3c",
+			BrushSize: 4,
+			Canvas:    Canvas{Width: 1920, Height: 1080},
+			Points:    []Point{{X: 0, Y: 0}, {X: 1000, Y: 700}},
+		}
+	}
+	tests := []struct {
+		description string
+		mutate      func(s *Stroke)
+		valid       bool
+	}{
+		{"Test pen stroke with coordinates beyond 255", func(s *Stroke) {}, true},
+		{"Test eraser stroke", func(s *Stroke) { s.Tool = TOOL_ERASER }, true},
+		{"Test fill with a single point", func(s *Stroke) { s.Tool = TOOL_FILL; s.BrushSize = 0; s.Points = s.Points[:1] }, true},
+		{"Test fill with several points", func(s *Stroke) { s.Tool = TOOL_FILL }, false},
+		{"Test unknown tool", func(s *Stroke) { s.Tool = "spray" }, false},
+		{"Test missing stroke id", func(s *Stroke) { s.StrokeId = 0 }, false},
+		{"Test stroke without points", func(s *Stroke) { s.Points = nil }, false},
+		{"Test stroke with too many points", func(s *Stroke) { s.Points = make([]Point, MAX_STROKE_POINTS+1) }, false},
+		{"Test malformed color", func(s *Stroke) { s.Color = "red" }, false},
+		{"Test zero brush size", func(s *Stroke) { s.BrushSize = 0 }, false},
+		{"Test oversized brush", func(s *Stroke) { s.BrushSize = MAX_BRUSH_SIZE + 1 }, false},
+		{"Test empty canvas", func(s *Stroke) { s.Canvas = Canvas{} }, false},
+		{"Test oversized canvas", func(s *Stroke) { s.Canvas.Width = MAX_CANVAS_SIZE + 1 }, false},
+		{"Test point outside the canvas", func(s *Stroke) { s.Points[1].X = 1920 }, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			stroke := valid()
+			tc.mutate(&stroke)
+			err := stroke.Validate()
+			if tc.valid {
+				assert.Nil(t, err)
+				return
+			}
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestLargestStrokeFitsInMessage(t *testing.T) {
+	stroke := Stroke{
+		StrokeId:  ^uint32(0),
+		Tool:      TOOL_ERASER,
+		Color:     "#ffffff",
+		BrushSize: MAX_BRUSH_SIZE,
+		Canvas:    Canvas{Width: MAX_CANVAS_SIZE, Height: MAX_CANVAS_SIZE},
+	}
+	for range MAX_STROKE_POINTS {
+		stroke.Points = append(stroke.Points, Point{X: MAX_CANVAS_SIZE - 1, Y: MAX_CANVAS_SIZE - 1})
+	}
+	assert.Nil(t, stroke.Validate())
+	frame, err := Encode(MSG_STROKE, ^uint64(0), stroke)
+	assert.Nil(t, err)
+	assert.Less(t, len(frame), MAX_MESSAGE_SIZE)
+}
+
+func TestDecodeServerMessage(t *testing.T) {
+	tests := []struct {
+		description     string
+		frame           string
+		expectedPayload any
+		expectedErrCode string
+	}{
+		{"Test relayed chat message", `{"version":1,"type":"chat","seq":3,"payload":{"player":"bob","text":"hello"}}`, &ChatEvent{Player: "bob", Text: "hello"}, ""},
+		{"Test hint", `{"version":1,"type":"hint","seq":4,"payload":{"hint":"_p__e"}}`, &HintEvent{Hint: "_p__e"}, ""},
+		{"Test payload with fields added by a newer server", `{"version":1,"type":"correct_guess","payload":{"player":"bob","points":100}}`, &CorrectGuessEvent{Player: "bob"}, ""},
+		{"Test unsupported protocol version", `{"version":9,"type":"hint","payload":{"hint":"_"}}`, nil, ERR_UNSUPPORTED_VERSION},
+		{"Test player message sent by the server", `{"version":1,"type":"choose_word","payload":{"word":"apple"}}`, nil, ERR_UNKNOWN_TYPE},
+		{"Test missing payload", `{"version":1,"type":"hint"}`, nil, ERR_INVALID_PAYLOAD},
+		{"Test payload of the wrong shape", `{"version":1,"type":"hint","payload":{"hint":42}}`, nil, ERR_INVALID_PAYLOAD},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			_, payload, err := DecodeServerMessage([]byte(tc.frame))
+			if len(tc.expectedErrCode) == 0 {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedPayload, payload)
+				return
+			}
+			protocolErr, ok := err.(*ProtocolError)
+			assert.True(t, ok, "Expected a ProtocolError")
+			assert.Equal(t, tc.expectedErrCode, protocolErr.Code)
+		})
+	}
+}
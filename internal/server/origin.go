@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// CORS_ALLOWED_HEADERS are the request headers browsers may send cross origin
+const CORS_ALLOWED_HEADERS = "Authorization, Content-Type"
+
+// CORS_ALLOWED_METHODS are the methods used by the REST routes
+const CORS_ALLOWED_METHODS = "GET, POST, DELETE, OPTIONS"
+
+// OriginPolicy decides which sites, besides the server's own, may talk to it
+// from a browser. Patterns are origins like "https://doodle.example.com", may
+// use a "*." prefix on the host to allow every subdomain or be "*" to allow any
+// origin at all
+type OriginPolicy struct {
+	anyOrigin bool
+	patterns  []*url.URL
+}
+
+// NewOriginPolicy parses a comma separated list of origin patterns
+func NewOriginPolicy(origins string) (*OriginPolicy, error) {
+	policy := &OriginPolicy{}
+	for _, origin := range strings.Split(origins, ",") {
+		origin = strings.TrimSpace(origin)
+		if len(origin) == 0 {
+			continue
+		}
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		pattern, err := url.Parse(strings.ToLower(origin))
+		if err != nil {
+			return nil, err
+		}
+		if len(pattern.Scheme) == 0 || len(pattern.Host) == 0 || (pattern.Path != "" && pattern.Path != "/") {
+			return nil, fmt.Errorf("Invalid origin %q, expected scheme://host[:port]", origin)
+		}
+		policy.patterns = append(policy.patterns, pattern)
+	}
+	return policy, nil
+}
+
+// Allows reports whether a request may be served given its Origin header.
+// Requests without one don't come from a browser page and same origin requests
+// are always fine
+func (p *OriginPolicy) Allows(request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || len(u.Host) == 0 {
+		return false
+	}
+	if u.Host == strings.ToLower(request.Host) {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.Scheme != u.Scheme || pattern.Port() != u.Port() {
+			continue
+		}
+		host := pattern.Hostname()
+		if domain, found := strings.CutPrefix(host, "*."); found {
+			if strings.HasSuffix(u.Hostname(), "."+domain) {
+				return true
+			}
+		} else if host == u.Hostname() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin is the websocket upgrader's origin check
+func (s *GameServer) checkOrigin(request *http.Request) bool {
+	if s.Origins.Allows(request) {
+		return true
+	}
+	s.Logger.Info(fmt.Sprintf("Rejected websocket upgrade from origin %s", request.Header.Get("Origin")))
+	return false
+}
+
+// cors rejects REST requests from origins outside the policy and adds the CORS
+// headers browsers need for the allowed ones. Websocket upgrades are left to
+// the upgrader's own origin check
+func (s *GameServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		origin := request.Header.Get("Origin")
+		if len(origin) == 0 || websocket.IsWebSocketUpgrade(request) {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		if !s.Origins.Allows(request) {
+			s.Logger.Info(fmt.Sprintf("Rejected %s %s from origin %s", request.Method, request.URL.Path, origin))
+			s.sendResponse(writer, []byte("Origin not allowed"), http.StatusForbidden)
+			return
+		}
+		header := writer.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+		header.Add("Vary", "Origin")
+		if request.Method == http.MethodOptions {
+			header.Set("Access-Control-Allow-Methods", CORS_ALLOWED_METHODS)
+			header.Set("Access-Control-Allow-Headers", CORS_ALLOWED_HEADERS)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
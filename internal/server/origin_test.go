@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	policy, err := NewOriginPolicy("https://doodle.example.com, https://*.play.example.com,http://localhost:3000")
+	require.Nil(t, err)
+	tests := []struct {
+		description string
+		origin      string
+		allowed     bool
+	}{
+		{"Test request without an origin", "", true},
+		{"Test same origin request", "http://api.example.com", true},
+		{"Test listed origin", "https://doodle.example.com", true},
+		{"Test listed origin in another case", "https://Doodle.Example.com", true},
+		{"Test listed origin with another scheme", "http://doodle.example.com", false},
+		{"Test subdomain of a wildcard origin", "https://eu.play.example.com", true},
+		{"Test nested subdomain of a wildcard origin", "https://a.eu.play.example.com", true},
+		{"Test bare domain of a wildcard origin", "https://play.example.com", false},
+		{"Test lookalike domain of a wildcard origin", "https://evilplay.example.com", false},
+		{"Test listed origin with its port", "http://localhost:3000", true},
+		{"Test listed origin with another port", "http://localhost:8080", false},
+		{"Test unlisted origin", "https://evil.com", false},
+		{"Test malformed origin", "null", false},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			request, err := http.NewRequest("GET", "http://api.example.com/api/v1/game", nil)
+			require.Nil(t, err)
+			if len(test.origin) != 0 {
+				request.Header.Set("Origin", test.origin)
+			}
+			assert.Equal(t, test.allowed, policy.Allows(request))
+		})
+	}
+}
+
+func TestOriginPolicyWildcard(t *testing.T) {
+	policy, err := NewOriginPolicy("*")
+	require.Nil(t, err)
+	request, err := http.NewRequest("GET", "http://api.example.com/api/v1/game", nil)
+	require.Nil(t, err)
+	request.Header.Set("Origin", "https://anywhere.com")
+	assert.True(t, policy.Allows(request))
+}
+
+func TestInvalidOriginPolicy(t *testing.T) {
+	for _, origins := range []string{"doodle.example.com", "https://doodle.example.com/play", "https://"} {
+		_, err := NewOriginPolicy(origins)
+		assert.NotNil(t, err, "Expected %q to be rejected", origins)
+	}
+}
@@ -2,18 +2,22 @@ package server
 
 import (
 	crypto "crypto/rand"
+	"github.com/anchal00/doodle/internal/clock"
 	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/gameid"
 	"github.com/anchal00/doodle/internal/logger"
 	"github.com/anchal00/doodle/internal/parser"
 	"github.com/anchal00/doodle/internal/state"
+	"github.com/anchal00/doodle/internal/words"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +28,23 @@ import (
 const HTTP_API_V1_PREFIX = "/api/v1"
 const MAX_ALLOWED_PLAYERS = 5
 const MAX_ALLOWED_ROUNDS = 5
+const SESSION_TOKEN_TTL = time.Hour
+
+// SELF_PLAYER_NAME is how the players/me routes refer to the player making
+// the request, so no player may go by it
+const SELF_PLAYER_NAME = "me"
+
+// Ways for players to present their session token
+const (
+	AUTH_COOKIE = "cookie"
+	AUTH_BEARER = "bearer"
+	AUTH_ANY    = "any"
+)
+
+// WEBSOCKET_PROTOCOL is the subprotocol the server speaks. Clients that can't
+// set headers on the handshake offer it along with TOKEN_PROTOCOL_PREFIX + token
+const WEBSOCKET_PROTOCOL = "doodle"
+const TOKEN_PROTOCOL_PREFIX = "doodle.token."
 
 type GameServer struct {
 	Db          db.Repository
@@ -32,10 +53,34 @@ type GameServer struct {
 	wssUpgrader websocket.Upgrader
 	Router      *mux.Router
 	GameState   state.StateStore
+	Words       words.WordBank
+	// ReconnectGrace overrides how long disconnected players keep their seat
+	ReconnectGrace time.Duration
+	// AuthScheme is one of AUTH_COOKIE, AUTH_BEARER or AUTH_ANY, the default
+	AuthScheme string
+	// Origins lists the other sites allowed to call the API from a browser
+	Origins *OriginPolicy
+	GameIds *gameid.Allocator
+	// Clock is shared with every game, tests swap it for a clock.Fake
+	Clock clock.Clock
+	// Reaper evicts finished and abandoned games while the server runs
+	Reaper *state.Reaper
+	// Snapshots is the GameState store when games are saved to survive restarts
+	Snapshots *state.SnapshotStore
+	// stop ends the Reaper and Snapshots background jobs
+	stop chan struct{}
+}
+
+func (s *GameServer) acceptsCookie() bool {
+	return s.AuthScheme != AUTH_BEARER
+}
+
+func (s *GameServer) acceptsBearer() bool {
+	return s.AuthScheme != AUTH_COOKIE
 }
 
-func (s *GameServer) UpgradeToWebsocket(writer http.ResponseWriter, request *http.Request) *websocket.Conn {
-	conn, err := s.wssUpgrader.Upgrade(writer, request, nil)
+func (s *GameServer) UpgradeToWebsocket(writer http.ResponseWriter, request *http.Request, responseHeader http.Header) *websocket.Conn {
+	conn, err := s.wssUpgrader.Upgrade(writer, request, responseHeader)
 	if err != nil {
 		s.Logger.Error("Failed to upgrade to WS connection", err)
 		return nil
@@ -62,6 +107,12 @@ func (s *GameServer) Run() {
 		s.Shutdown()
 		os.Exit(0)
 	}()
+	if s.Reaper != nil {
+		go s.Reaper.Run(state.REAP_INTERVAL, s.stop)
+	}
+	if s.Snapshots != nil {
+		go s.Snapshots.Run(state.SNAPSHOT_INTERVAL, s.stop)
+	}
 	if err := http.ListenAndServe(fmt.Sprintf(":%s", s.port), s.Router); err != nil {
 		s.Logger.Error(fmt.Sprintf("Failed to start server on port %s", s.port), err)
 		return
@@ -70,12 +121,37 @@ func (s *GameServer) Run() {
 
 func (s *GameServer) Shutdown() {
 	s.Logger.Info("Shutting down server....")
+	if s.stop != nil {
+		close(s.stop)
+	}
+	if s.Snapshots != nil {
+		s.Snapshots.SaveAll()
+	}
 	s.Db.CloseConnection()
 	s.Logger.Info("Goodbye !")
 }
 
+func isValidPlayerName(name string) bool {
+	return len(name) != 0 && name != SELF_PLAYER_NAME
+}
+
 func isValidNewGameRequest(gameRequest parser.CreateGameRequest) bool {
-	return len(gameRequest.Player) != 0
+	if !isValidPlayerName(gameRequest.Player) {
+		return false
+	}
+	scoring := gameRequest.Scoring
+	return scoring == nil || scoring.GuessMinPoints <= scoring.GuessMaxPoints
+}
+
+func scoringRulesOf(gameRequest parser.CreateGameRequest) db.ScoringRules {
+	if gameRequest.Scoring == nil {
+		return state.DEFAULT_SCORING_RULES
+	}
+	return db.ScoringRules{
+		GuessMaxPoints: gameRequest.Scoring.GuessMaxPoints,
+		GuessMinPoints: gameRequest.Scoring.GuessMinPoints,
+		DrawerPoints:   gameRequest.Scoring.DrawerPoints,
+	}
 }
 
 func (s *GameServer) sendResponse(writer http.ResponseWriter, responseBody []byte, status int) {
@@ -91,25 +167,30 @@ func (s *GameServer) sendResponse(writer http.ResponseWriter, responseBody []byt
 	}
 }
 
-func (s *GameServer) attachSessionToken(writer http.ResponseWriter) (string, error) {
+func (s *GameServer) attachSessionToken(writer http.ResponseWriter) (db.SessionToken, error) {
 	token, err := createSessionToken()
 	if err != nil {
 		s.sendResponse(writer, nil, http.StatusInternalServerError)
 		s.Logger.Error("CreateNewGame request failed: Unable to create session token", err)
-		return "", err
+		return db.SessionToken{}, err
 	}
-	expirationTime := time.Now().Add(60 * time.Minute)
-	cookie := &http.Cookie{
+	sessionToken := db.SessionToken{Value: token, ExpiresAt: s.Clock.Now().Add(SESSION_TOKEN_TTL)}
+	if s.acceptsCookie() {
+		http.SetCookie(writer, sessionCookie(sessionToken))
+	}
+	return sessionToken, nil
+}
+
+func sessionCookie(token db.SessionToken) *http.Cookie {
+	return &http.Cookie{
 		Name:     "session-token",
-		Value:    token,
+		Value:    token.Value,
 		HttpOnly: true,
 		Secure:   false,
 		Path:     fmt.Sprintf("%s/connect", HTTP_API_V1_PREFIX),
 		SameSite: http.SameSiteStrictMode,
-		Expires:  expirationTime,
+		Expires:  token.ExpiresAt,
 	}
-	http.SetCookie(writer, cookie)
-	return token, nil
 }
 
 func (s *GameServer) CreateNewGame(writer http.ResponseWriter, request *http.Request) {
@@ -132,8 +213,6 @@ func (s *GameServer) CreateNewGame(writer http.ResponseWriter, request *http.Req
 		s.Logger.Error("Bad game request", nil)
 		return
 	}
-	gameId := getRandomGameId(6)
-	// TODO: gameId could possibly be duplicate, fix this
 	gameRequest.MaxPlayerCount = min(MAX_ALLOWED_PLAYERS, gameRequest.MaxPlayerCount)
 	gameRequest.TotalRounds = min(MAX_ALLOWED_ROUNDS, gameRequest.TotalRounds)
 	authToken, err := s.attachSessionToken(writer)
@@ -141,16 +220,29 @@ func (s *GameServer) CreateNewGame(writer http.ResponseWriter, request *http.Req
 		s.Logger.Error("CreateNewGame request failed", err)
 		return
 	}
-	err = s.Db.CreateNewGame(gameId, gameRequest.Player, authToken, gameRequest.MaxPlayerCount, gameRequest.TotalRounds)
+	hints := uint8(state.DEFAULT_HINT_COUNT)
+	if gameRequest.Hints != nil {
+		hints = min(state.MAX_HINT_COUNT, *gameRequest.Hints)
+	}
+	gameId, err := s.createGame(*gameRequest, authToken, hints)
 	if err != nil {
 		s.sendResponse(writer, nil, http.StatusBadRequest)
 		s.Logger.Error("CreateNewGame request failed", err)
 		return
 	}
-	s.GameState.SetGameState(gameId, state.InitGameState(gameId, s.Db))
-	// TODO: The player who created the game needs to connect via ws now
-	// to be able to receieve updates of the others joining etc.
-	respBody, err := json.Marshal(parser.CreateGameResponse{GameId: gameId})
+	// The creator connects over websocket next to follow players joining the lobby
+	gs := state.InitGameState(gameId, s.Db, s.Words, s.Clock)
+	if s.ReconnectGrace != 0 {
+		gs.SetReconnectGrace(s.ReconnectGrace)
+	}
+	// Players who stay connected for longer than their token lasts keep their session
+	gs.SetSessionTTL(SESSION_TOKEN_TTL)
+	s.GameState.SetGameState(gameId, gs)
+	response := parser.CreateGameResponse{GameId: gameId}
+	if s.acceptsBearer() {
+		response.Token, response.TokenExpiresAt = authToken.Value, &authToken.ExpiresAt
+	}
+	respBody, err := json.Marshal(response)
 	if err != nil {
 		s.sendResponse(writer, nil, http.StatusInternalServerError)
 		return
@@ -158,6 +250,28 @@ func (s *GameServer) CreateNewGame(writer http.ResponseWriter, request *http.Req
 	s.sendResponse(writer, respBody, http.StatusCreated)
 }
 
+// createGame saves the new game under a fresh id. The StateStore is checked up
+// front and the database's own constraint catches ids taken in the meantime,
+// both count against the allocator's attempts
+func (s *GameServer) createGame(gameRequest parser.CreateGameRequest, authToken db.SessionToken, hints uint8) (string, error) {
+	return s.GameIds.Claim(func(gameId string) error {
+		if s.gameIdTaken(gameId) {
+			return gameid.ErrTaken
+		}
+		err := s.Db.CreateNewGame(gameId, gameRequest.Player, authToken, gameRequest.MaxPlayerCount, gameRequest.TotalRounds, hints, scoringRulesOf(gameRequest))
+		if errors.Is(err, db.ErrGameExists) {
+			s.Logger.Info(fmt.Sprintf("Game id %s is already taken, retrying", gameId))
+			return gameid.ErrTaken
+		}
+		return err
+	})
+}
+
+func (s *GameServer) gameIdTaken(gameId string) bool {
+	_, err := s.GameState.GetGameState(gameId)
+	return err == nil
+}
+
 func (s *GameServer) JoinGame(writer http.ResponseWriter, request *http.Request) {
 	gameId := mux.Vars(request)["gameId"]
 	s.Logger.Info(fmt.Sprintf("Player is joining game %s", gameId))
@@ -172,6 +286,11 @@ func (s *GameServer) JoinGame(writer http.ResponseWriter, request *http.Request)
 		s.sendResponse(writer, nil, http.StatusBadRequest)
 		return
 	}
+	if !isValidPlayerName(joinGameRequest.Player) {
+		s.Logger.Error("Bad join game request", fmt.Errorf("Player name %q is not allowed", joinGameRequest.Player))
+		s.sendResponse(writer, nil, http.StatusBadRequest)
+		return
+	}
 	game := s.Db.GetGameById(gameId)
 	if game == nil {
 		s.Logger.Error("Unrecognized game id", err)
@@ -200,9 +319,13 @@ func (s *GameServer) JoinGame(writer http.ResponseWriter, request *http.Request)
 		return
 	}
 	gs.Refresh()
-	respBody, err := json.Marshal(parser.JoinGameResponse{
+	response := parser.JoinGameResponse{
 		GameUrl: fmt.Sprintf("http://127.0.0.1:%s%s/%s", s.port, HTTP_API_V1_PREFIX, gameId),
-	})
+	}
+	if s.acceptsBearer() {
+		response.Token, response.TokenExpiresAt = authToken.Value, &authToken.ExpiresAt
+	}
+	respBody, err := json.Marshal(response)
 	if err != nil {
 		s.sendResponse(writer, nil, http.StatusInternalServerError)
 		return
@@ -210,28 +333,89 @@ func (s *GameServer) JoinGame(writer http.ResponseWriter, request *http.Request)
 	s.sendResponse(writer, respBody, http.StatusOK)
 }
 
+// authorizePlayer finds the player owning the request's session token, unknown
+// and expired tokens are rejected
 func (s *GameServer) authorizePlayer(gameId string, request *http.Request) (*db.Player, error) {
-	cookie, err := request.Cookie("session-token")
+	token, err := s.sessionToken(request)
 	if err != nil {
 		return nil, err
 	}
+	player := s.Db.GetGamePlayerByToken(gameId, token, s.Clock.Now())
+	if player == nil {
+		return nil, fmt.Errorf("Session token is not valid for game %s", gameId)
+	}
+	return player, nil
+}
+
+// sessionToken reads the request's session token from wherever the auth scheme
+// allows: an Authorization header, a websocket subprotocol or the session cookie
+func (s *GameServer) sessionToken(request *http.Request) (string, error) {
+	if s.acceptsBearer() {
+		if token, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer "); found {
+			return strings.TrimSpace(token), nil
+		}
+		for _, protocol := range websocket.Subprotocols(request) {
+			if token, found := strings.CutPrefix(protocol, TOKEN_PROTOCOL_PREFIX); found {
+				return token, nil
+			}
+		}
+	}
+	if !s.acceptsCookie() {
+		return "", errors.New("No bearer token found")
+	}
+	cookie, err := request.Cookie("session-token")
+	if err != nil {
+		return "", err
+	}
 	if err := cookie.Valid(); err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// refreshSession pushes back the expiry of the request's session token so that
+// players in a long game aren't locked out, it returns the renewed cookie
+func (s *GameServer) refreshSession(player *db.Player, request *http.Request) (*http.Cookie, error) {
+	value, err := s.sessionToken(request)
+	if err != nil {
 		return nil, err
 	}
-	// TODO: Store auth token expiry time in db as well and verify token's validity here
-	token := cookie.Value
-	player := s.Db.GetGamePlayerByToken(gameId, token)
-	return player, nil
+	token := db.SessionToken{Value: value, ExpiresAt: s.Clock.Now().Add(SESSION_TOKEN_TTL)}
+	if err := s.Db.RefreshToken(player.GameId, player.Name, token.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return sessionCookie(token), nil
 }
 
-func (s *GameServer) StartGame(writer http.ResponseWriter, request *http.Request) {
+// RefreshSession lets clients keep their session alive while they are connected to a game
+func (s *GameServer) RefreshSession(writer http.ResponseWriter, request *http.Request) {
 	gameId := mux.Vars(request)["gameId"]
 	player, err := s.authorizePlayer(gameId, request)
 	if err != nil {
-		s.Logger.Error("Malformed cookie", err)
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
+	cookie, err := s.refreshSession(player, request)
+	if err != nil {
+		s.Logger.Error("Failed to refresh session", err)
 		s.sendResponse(writer, nil, http.StatusInternalServerError)
 		return
 	}
+	if s.acceptsCookie() {
+		http.SetCookie(writer, cookie)
+	}
+	s.sendResponse(writer, nil, http.StatusNoContent)
+}
+
+func (s *GameServer) StartGame(writer http.ResponseWriter, request *http.Request) {
+	gameId := mux.Vars(request)["gameId"]
+	player, err := s.authorizePlayer(gameId, request)
+	if err != nil {
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
 	if !player.IsAdmin {
 		s.Logger.Error("Attempt to start the game from a Non-Admin player", err)
 		s.sendResponse(writer, nil, http.StatusForbidden)
@@ -243,25 +427,116 @@ func (s *GameServer) StartGame(writer http.ResponseWriter, request *http.Request
 		s.sendResponse(writer, nil, http.StatusBadRequest)
 		return
 	}
-	go gs.StartGameLoop()
+	if err := gs.Start(); err != nil {
+		s.Logger.Error("Failed to start game", err)
+		s.sendResponse(writer, nil, http.StatusConflict)
+		return
+	}
 	s.sendResponse(writer, nil, http.StatusOK)
 }
 
+// Rematch brings a finished game back to the lobby, keeping its players
+func (s *GameServer) Rematch(writer http.ResponseWriter, request *http.Request) {
+	gameId := mux.Vars(request)["gameId"]
+	player, err := s.authorizePlayer(gameId, request)
+	if err != nil {
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
+	if !player.IsAdmin {
+		s.Logger.Error("Attempt to start a rematch from a Non-Admin player", err)
+		s.sendResponse(writer, nil, http.StatusForbidden)
+		return
+	}
+	gs, err := s.GameState.GetGameState(gameId)
+	if err != nil {
+		s.Logger.Error("GameStateError", err)
+		s.sendResponse(writer, nil, http.StatusBadRequest)
+		return
+	}
+	if err := gs.Rematch(); err != nil {
+		s.Logger.Error("Failed to start a rematch", err)
+		s.sendResponse(writer, nil, http.StatusConflict)
+		return
+	}
+	s.sendResponse(writer, nil, http.StatusOK)
+}
+
+// LeaveGame takes the calling player out of the game
+func (s *GameServer) LeaveGame(writer http.ResponseWriter, request *http.Request) {
+	gameId := mux.Vars(request)["gameId"]
+	player, err := s.authorizePlayer(gameId, request)
+	if err != nil {
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
+	s.removePlayer(writer, gameId, player.Name, parser.LEFT_QUIT)
+}
+
+// KickPlayer lets the admin remove another player from the game
+func (s *GameServer) KickPlayer(writer http.ResponseWriter, request *http.Request) {
+	gameId := mux.Vars(request)["gameId"]
+	player, err := s.authorizePlayer(gameId, request)
+	if err != nil {
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
+	if !player.IsAdmin {
+		s.Logger.Error("Attempt to kick a player from a Non-Admin player", err)
+		s.sendResponse(writer, nil, http.StatusForbidden)
+		return
+	}
+	s.removePlayer(writer, gameId, mux.Vars(request)["name"], parser.LEFT_KICKED)
+}
+
+func (s *GameServer) removePlayer(writer http.ResponseWriter, gameId, player, reason string) {
+	gs, err := s.GameState.GetGameState(gameId)
+	if err != nil {
+		s.Logger.Error("GameStateError", err)
+		s.sendResponse(writer, nil, http.StatusBadRequest)
+		return
+	}
+	if !gs.HasPlayer(player) {
+		s.Logger.Debug(fmt.Sprintf("Player %s is not part of game %s", player, gameId))
+		s.sendResponse(writer, nil, http.StatusNotFound)
+		return
+	}
+	if err := gs.RemoveConnection(player, reason); err != nil {
+		s.Logger.Error(fmt.Sprintf("Failed to remove player %s", player), err)
+		s.sendResponse(writer, nil, http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(writer, nil, http.StatusNoContent)
+}
+
 func (s *GameServer) Connect(writer http.ResponseWriter, request *http.Request) {
 	gameId := mux.Vars(request)["gameId"]
 	s.Logger.Info(fmt.Sprintf("Player is sending an update to game %s", gameId))
 	// Authorize player
 	player, err := s.authorizePlayer(gameId, request)
 	if err != nil {
-		s.Logger.Error("Malformed cookie", err)
-		s.sendResponse(writer, nil, http.StatusInternalServerError)
+		s.Logger.Error("Unauthorized request", err)
+		s.sendResponse(writer, nil, http.StatusUnauthorized)
+		return
+	}
+	responseHeader := http.Header{}
+	if cookie, err := s.refreshSession(player, request); err == nil && s.acceptsCookie() {
+		responseHeader.Add("Set-Cookie", cookie.String())
+	} else if err != nil {
+		s.Logger.Error("Failed to refresh session", err)
+	}
+	wssConn := s.UpgradeToWebsocket(writer, request, responseHeader)
+	if wssConn == nil {
 		return
 	}
-	wssConn := s.UpgradeToWebsocket(writer, request)
 	gs, err := s.GameState.GetGameState(gameId)
 	if err != nil {
 		s.Logger.Error("GameStateError", err)
-		s.sendResponse(writer, nil, http.StatusBadRequest)
+		// The game may have been evicted since the player was authorized
+		wssConn.Close()
 		return
 	}
 	gs.AddConnection(player.Name, wssConn)
@@ -272,26 +547,132 @@ func NewGameServer(port string) (*GameServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := repo.AddWords(words.DEFAULT_WORDS); err != nil {
+		return nil, err
+	}
+	if wordList := os.Getenv("DOODLE_WORDS_FILE"); len(wordList) != 0 {
+		if err := words.LoadWordList(repo, wordList); err != nil {
+			repo.CloseConnection()
+			return nil, err
+		}
+	}
+	authScheme := os.Getenv("DOODLE_AUTH_SCHEME")
+	switch authScheme {
+	case "":
+		authScheme = AUTH_ANY
+	case AUTH_COOKIE, AUTH_BEARER, AUTH_ANY:
+	default:
+		repo.CloseConnection()
+		return nil, fmt.Errorf("Unknown auth scheme %q", authScheme)
+	}
+	origins, err := NewOriginPolicy(os.Getenv("DOODLE_ALLOWED_ORIGINS"))
+	if err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	gameIds, err := gameIdAllocatorFromEnv()
+	if err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	reconnectGrace, err := durationFromEnv("DOODLE_RECONNECT_GRACE", state.RECONNECT_GRACE_PERIOD)
+	if err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	finishedTTL, err := durationFromEnv("DOODLE_FINISHED_GAME_TTL", state.FINISHED_GAME_TTL)
+	if err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	abandonedTTL, err := durationFromEnv("DOODLE_ABANDONED_GAME_TTL", state.ABANDONED_GAME_TTL)
+	if err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	wordBank := words.NewRepositoryWordBank(repo)
+	wallClock := clock.New()
+	gameStates := state.NewSnapshotStore(repo, wordBank, wallClock)
+	if err := gameStates.Restore(); err != nil {
+		repo.CloseConnection()
+		return nil, err
+	}
+	for _, restored := range gameStates.All() {
+		restored.SetReconnectGrace(reconnectGrace)
+		restored.SetSessionTTL(SESSION_TOKEN_TTL)
+	}
 	router := mux.NewRouter().PathPrefix(HTTP_API_V1_PREFIX).Subrouter()
 	gs := &GameServer{
 		Db:     repo,
 		Logger: logger.New("api_server"),
 		port:   port,
 		wssUpgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			Subprotocols: []string{WEBSOCKET_PROTOCOL},
 		},
-		Router:    router,
-		GameState: state.NewInMemoryGameStore(),
-	}
+		Router:         router,
+		GameState:      gameStates,
+		Words:          wordBank,
+		ReconnectGrace: reconnectGrace,
+		AuthScheme:     authScheme,
+		Origins:        origins,
+		GameIds:        gameIds,
+		Clock:          wallClock,
+		Reaper:         state.NewReaper(gameStates, repo, wallClock, finishedTTL, abandonedTTL),
+		Snapshots:      gameStates,
+		stop:           make(chan struct{}),
+	}
+	gs.wssUpgrader.CheckOrigin = gs.checkOrigin
 	gs.setupRoutes()
 	return gs, nil
 }
 
 func (s *GameServer) setupRoutes() {
+	s.Router.Use(s.cors)
 	s.Router.HandleFunc("/game", s.CreateNewGame).Methods("POST")
-	s.Router.HandleFunc("/game/{gameId:[a-z]+}", s.JoinGame).Methods("POST")
-	s.Router.HandleFunc("/game/{gameId:[a-z]+}/start", s.StartGame).Methods("POST")
-	s.Router.HandleFunc("/connect/game/{gameId:[a-z]+}", s.Connect)
+	s.Router.HandleFunc("/game/{gameId:[a-z0-9-]+}", s.JoinGame).Methods("POST")
+	s.Router.HandleFunc("/game/{gameId:[a-z0-9-]+}/start", s.StartGame).Methods("POST")
+	s.Router.HandleFunc("/game/{gameId:[a-z0-9-]+}/rematch", s.Rematch).Methods("POST")
+	// "me" has to be matched before a player's name
+	s.Router.HandleFunc("/game/{gameId:[a-z0-9-]+}/players/"+SELF_PLAYER_NAME, s.LeaveGame).Methods("DELETE")
+	s.Router.HandleFunc("/game/{gameId:[a-z0-9-]+}/players/{name}", s.KickPlayer).Methods("DELETE")
+	s.Router.HandleFunc("/connect/game/{gameId:[a-z0-9-]+}", s.Connect)
+	s.Router.HandleFunc("/connect/game/{gameId:[a-z0-9-]+}/session", s.RefreshSession).Methods("POST")
+	// Preflight requests are answered by the cors middleware
+	s.Router.Methods("OPTIONS").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {})
+}
+
+// durationFromEnv parses the duration set in env variable name, e.g. "15m",
+// falling back to fallback if it isn't set
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %w", name, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+	return duration, nil
+}
+
+// gameIdAllocatorFromEnv reads DOODLE_GAME_ID_STYLE, DOODLE_GAME_ID_LENGTH and
+// DOODLE_GAME_ID_ALPHABET, using the defaults for whichever isn't set
+func gameIdAllocatorFromEnv() (*gameid.Allocator, error) {
+	config := gameid.Config{
+		Style:    os.Getenv("DOODLE_GAME_ID_STYLE"),
+		Alphabet: os.Getenv("DOODLE_GAME_ID_ALPHABET"),
+	}
+	if length := os.Getenv("DOODLE_GAME_ID_LENGTH"); len(length) != 0 {
+		n, err := strconv.Atoi(length)
+		if err != nil {
+			return nil, err
+		}
+		config.Length = n
+	}
+	return gameid.New(config)
 }
 
 func createSessionToken() (string, error) {
@@ -303,12 +684,3 @@ func createSessionToken() (string, error) {
 	// Convert bytes to a hex string
 	return hex.EncodeToString(token), nil
 }
-
-func getRandomGameId(size int) string {
-	r := make([]byte, size)
-	for i := 0; i < size; i += 1 {
-		offset := rand.Intn(26)
-		r[i] = byte(97 + offset)
-	}
-	return string(r)
-}
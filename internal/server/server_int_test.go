@@ -1,18 +1,18 @@
 package server
 
 import (
-	"bytes"
-	"github.com/anchal00/doodle/internal/parser"
-	"encoding/json"
+	"github.com/anchal00/doodle/client"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
-	"io"
+	"github.com/stretchr/testify/require"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
@@ -22,6 +22,10 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 	go gs.Run()
+	if !waitForServer(os.Getenv("DOODLE_PORT")) {
+		log.Print("GameServer did not come up in time")
+		os.Exit(1)
+	}
 	exitCode := m.Run()
 	tearDown(gs)
 	os.Exit(exitCode)
@@ -42,6 +46,18 @@ func setup() *GameServer {
 	return gs
 }
 
+func waitForServer(port string) bool {
+	for attempt := 0; attempt < 50; attempt += 1 {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%s", port))
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
 func tearDown(gs *GameServer) {
 	gs.Shutdown()
 	// remove sqlite file
@@ -51,52 +67,106 @@ func tearDown(gs *GameServer) {
 	}
 }
 
-func apiCall(method, path string, requestBody io.Reader) (*http.Response, error) {
-	url := fmt.Sprintf("http://localhost:%s%s%s", os.Getenv("DOODLE_PORT"), HTTP_API_V1_PREFIX, path)
-	req, err := http.NewRequest(method, url, requestBody)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("Sending %s request to endpoint %s ", req.Method, req.URL)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("%s request to endpoint %s failed with error %s ", req.Method, req.URL, err.Error())
-		return nil, err
-	}
-	log.Printf("%s request to endpoint %s returned status %d", req.Method, req.URL, resp.StatusCode)
-	return resp, nil
+func baseURL() string {
+	return fmt.Sprintf("http://localhost:%s", os.Getenv("DOODLE_PORT"))
 }
 
 func TestGameFlow(t *testing.T) {
 	// Create new game
-	createGameRequestBody, err := json.Marshal(map[string]any{
-		"player":       "rookie",
-		"max_players":  5,
-		"total_rounds": 4,
-	})
-	assert.Nil(t, err, "Failed to create CreateGame request body")
-	resp, err := apiCall("POST", "/game", bytes.NewBuffer(createGameRequestBody))
-	assert.Nil(t, err, "Failed to execute CreateGame api call")
-	assert.Equal(t, http.StatusCreated, resp.StatusCode, "Failed to create new game")
-	respBody, err := ReadResponseBody(resp)
-	assert.Nil(t, err, "Failed to read CreateGame response body")
-	createGameResponse := &parser.CreateGameResponse{}
-	err = json.Unmarshal(respBody, &createGameResponse)
-	assert.Nil(t, err, "Failed to deserialize CreateGame response body")
-	gameId := createGameResponse.GameId
-	assert.NotNil(t, gameId, "Failed to extract game id from CreateGame response body")
+	admin := client.New(baseURL())
+	gameId, err := admin.CreateGame(client.CreateGameRequest{Player: "rookie", MaxPlayerCount: 5, TotalRounds: 4})
+	assert.Nil(t, err, "Failed to create new game")
+	assert.NotEmpty(t, gameId, "Failed to extract game id from CreateGame response body")
 	// Add players to game
 	for player := 1; player <= 4; player += 1 {
-		addPlayerRequestBody, err := json.Marshal(parser.JoinGameRequest{Player: fmt.Sprintf("player%d", player)})
-		assert.Nil(t, err, "Failed to create AddPlayer request body")
-		resp, err = apiCall("POST", fmt.Sprintf("/game/%s", gameId), bytes.NewBuffer(addPlayerRequestBody))
-		assert.Nil(t, err, "Failed to execute AddPlayer api call")
-		assert.Equal(t, http.StatusOK, resp.StatusCode, "Failed to add new player to the game")
+		err := client.New(baseURL()).JoinGame(gameId, fmt.Sprintf("player%d", player))
+		assert.Nil(t, err, "Failed to add new player to the game")
 	}
 	// Adding more players should be disallowed as we have added 5 players (including the player who had created the game)
-	addPlayerRequestBody, err := json.Marshal(parser.JoinGameRequest{Player: "playerlast"})
-	assert.Nil(t, err, "Failed to create AddPlayer request body")
-	resp, err = apiCall("POST", fmt.Sprintf("/game/%s", gameId), bytes.NewBuffer(addPlayerRequestBody))
-	assert.Nil(t, err, "Failed to execute AddPlayer api call")
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Player limit has been exhausted, expected the join request to be rejected")
+	err = client.New(baseURL()).JoinGame(gameId, "playerlast")
+	statusErr, ok := err.(*client.StatusError)
+	assert.True(t, ok, "Expected the join request to fail with a StatusError")
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode, "Player limit has been exhausted, expected the join request to be rejected")
+}
+
+// connect opens the player's websocket and reads the canvas snapshot sent on connecting
+func connect(t *testing.T, player *client.Client) *client.Conn {
+	conn, err := player.Connect()
+	require.Nil(t, err, "Failed to connect player %s", player.Player())
+	t.Cleanup(func() { conn.Close() })
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	event, err := conn.Receive()
+	require.Nil(t, err)
+	require.Equal(t, client.MSG_CANVAS_SNAPSHOT, event.Type)
+	return conn
+}
+
+func TestPlayingOverWebsocket(t *testing.T) {
+	alice, bob := client.New(baseURL()), client.New(baseURL())
+	gameId, err := alice.CreateGame(client.CreateGameRequest{Player: "alice", MaxPlayerCount: 2, TotalRounds: 1})
+	require.Nil(t, err)
+	require.Nil(t, bob.JoinGame(gameId, "bob"))
+	conns := map[string]*client.Conn{"alice": connect(t, alice), "bob": connect(t, bob)}
+	event, err := conns["alice"].WaitFor(client.MSG_LOBBY)
+	require.Nil(t, err)
+	assert.Len(t, event.Payload.(*client.Lobby).Players, 2)
+
+	err = bob.StartGame()
+	statusErr, ok := err.(*client.StatusError)
+	require.True(t, ok, "Only the admin may start the game")
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	require.Nil(t, alice.StartGame())
+	event, err = conns["bob"].WaitFor(client.MSG_CHOOSING_WORD, client.MSG_WORD_CHOICES)
+	require.Nil(t, err)
+	drawer, guesser := "alice", "bob"
+	if event.Type == client.MSG_WORD_CHOICES {
+		drawer, guesser = "bob", "alice"
+	} else {
+		event, err = conns[drawer].WaitFor(client.MSG_WORD_CHOICES)
+		require.Nil(t, err)
+	}
+	word := event.Payload.(*client.WordChoicesEvent).Words[0]
+	_, err = conns[drawer].ChooseWord(word)
+	require.Nil(t, err)
+	event, err = conns[guesser].WaitFor(client.MSG_TURN_START)
+	require.Nil(t, err)
+	turnStart := event.Payload.(*client.TurnStartEvent)
+	assert.Equal(t, drawer, turnStart.Drawer)
+	assert.Equal(t, len([]rune(word)), turnStart.WordLength)
+
+	stroke := client.Stroke{
+		StrokeId:  1,
+		Tool:      client.TOOL_PEN,
+		Color:     "#000000",
+		BrushSize: 4,
+		Canvas:    client.Canvas{Width: 800, Height: 600},
+		Points:    []client.Point{{X: 10, Y: 10}, {X: 20, Y: 20}},
+	}
+	_, err = conns[drawer].Draw(stroke)
+	require.Nil(t, err)
+	event, err = conns[guesser].WaitFor(client.MSG_STROKE)
+	require.Nil(t, err)
+	assert.Equal(t, &client.StrokeEvent{Drawer: drawer, Stroke: stroke}, event.Payload)
+
+	seq, err := conns[guesser].Draw(stroke)
+	require.Nil(t, err)
+	event, err = conns[guesser].WaitFor(client.MSG_ERROR)
+	require.Nil(t, err)
+	assert.Equal(t, seq, event.Payload.(*client.ErrorEvent).InReplyTo, "Only the drawer may draw")
+
+	_, err = conns[guesser].Guess(word)
+	require.Nil(t, err)
+	event, err = conns[drawer].WaitFor(client.MSG_CORRECT_GUESS)
+	require.Nil(t, err)
+	assert.Equal(t, guesser, event.Payload.(*client.CorrectGuessEvent).Player)
+	event, err = conns[guesser].WaitFor(client.MSG_SCORE_UPDATE)
+	require.Nil(t, err)
+	assert.Contains(t, event.Payload.(*client.ScoreUpdateEvent).Deltas, guesser)
+
+	require.Nil(t, bob.Leave())
+	event, err = conns["alice"].WaitFor(client.MSG_PLAYER_LEFT)
+	require.Nil(t, err)
+	playerLeft := event.Payload.(*client.PlayerLeftEvent)
+	assert.Equal(t, "bob", playerLeft.Player)
+	assert.Equal(t, client.LEFT_QUIT, playerLeft.Reason)
 }
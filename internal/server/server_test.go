@@ -2,12 +2,15 @@ package server
 
 import (
 	"bytes"
+	"github.com/anchal00/doodle/internal/clock"
 	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/gameid"
 	dbMock "github.com/anchal00/doodle/internal/db/mocks"
 	"github.com/anchal00/doodle/internal/logger"
 	"github.com/anchal00/doodle/internal/parser"
 	"github.com/anchal00/doodle/internal/state"
 	stateStoreMock "github.com/anchal00/doodle/internal/state/mocks"
+	"github.com/anchal00/doodle/internal/words"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -51,14 +54,27 @@ func CreateMockGameServer(t *testing.T, db *dbMock.Repository, stateStore *state
 		Db:          db,
 		Logger:      logger.New("server_test_logger"),
 		port:        "9999",
-		wssUpgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		wssUpgrader: websocket.Upgrader{Subprotocols: []string{WEBSOCKET_PROTOCOL}},
 		Router:      router,
 		GameState:   stateStore,
+		Words:       words.NewStaticWordBank(words.DEFAULT_WORDS),
+		Clock:       clock.New(),
 	}
+	gameIds, err := gameid.New(gameid.DEFAULT_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.GameIds = gameIds
+	gs.wssUpgrader.CheckOrigin = gs.checkOrigin
 	gs.setupRoutes()
 	return gs
 }
 
+// expectFreeGameIds reports every game id as unused by the StateStore
+func (suite *GameServerTestSuite) expectFreeGameIds() {
+	suite.stateMock.On("GetGameState", mock.Anything).Return(nil, fmt.Errorf("No state found")).Maybe()
+}
+
 func ReadResponseBody(response *http.Response) ([]byte, error) {
 	bodyReader := response.Body
 	bytesRead, err := io.ReadAll(bodyReader)
@@ -80,13 +96,15 @@ func (suite *GameServerTestSuite) TestCreateNewGame() {
 		{"Test with valid new game request", "rookie", 5, 4, http.StatusCreated},
 		{"Test with player name containing all whitespaces", "     ", 5, 4, http.StatusBadRequest},
 		{"Test with empty player name", "", 5, 4, http.StatusBadRequest},
+		{"Test with the name of the players/me route", "me", 5, 4, http.StatusBadRequest},
 		{"Test with invalid rounds and player count", "rookie", -5, -4, http.StatusBadRequest},
 		{"Test with invalid player count", "rookie", -5, 4, http.StatusBadRequest},
 		{"Test with invalid round count", "rookie", 5, -4, http.StatusBadRequest},
 	}
 	for _, tc := range tests {
 		suite.Run(tc.description, func() {
-			suite.dbMock.On("CreateNewGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			suite.expectFreeGameIds()
+			suite.dbMock.On("CreateNewGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 			suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
 			if tc.expectedStatusCode == http.StatusCreated {
 				suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
@@ -115,6 +133,8 @@ func (suite *GameServerTestSuite) TestCreateNewGame() {
 			gameId := createGameResponse.GameId
 			suite.NotNil(gameId, "Failed to extract game id from CreateGame response body")
 			assertCookieValid(suite, resp)
+			suite.Equal(resp.Cookies()[0].Value, createGameResponse.Token, "Response token must match the session cookie")
+			suite.NotNil(createGameResponse.TokenExpiresAt, "Response token expiry is missing")
 		})
 	}
 }
@@ -151,7 +171,8 @@ func (suite *GameServerTestSuite) TestCreateNewGameExceedingMaxAllowedPlayersAnd
 		PlayerCount: 1,
 	}
 	suite.dbMock.On("GetGameById", mock.Anything).Return(&mockGameObject)
-	suite.dbMock.On("CreateNewGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.expectFreeGameIds()
+	suite.dbMock.On("CreateNewGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
 	suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
 	createGameRequestBody, err := json.Marshal(createGameRequest)
@@ -162,6 +183,41 @@ func (suite *GameServerTestSuite) TestCreateNewGameExceedingMaxAllowedPlayersAnd
 	assertCookieValid(suite, resp)
 }
 
+func (suite *GameServerTestSuite) TestCreateNewGameWithScoringRules() {
+	url := suite.server.URL + HTTP_API_V1_PREFIX + "/game"
+	tests := []struct {
+		description        string
+		scoring            *parser.ScoringRules
+		expectedRules      db.ScoringRules
+		expectedStatusCode int
+	}{
+		{"Test default scoring rules", nil, state.DEFAULT_SCORING_RULES, http.StatusCreated},
+		{"Test custom scoring rules", &parser.ScoringRules{GuessMaxPoints: 50, GuessMinPoints: 10, DrawerPoints: 5}, db.ScoringRules{GuessMaxPoints: 50, GuessMinPoints: 10, DrawerPoints: 5}, http.StatusCreated},
+		{"Test minimum guess points exceeding maximum", &parser.ScoringRules{GuessMaxPoints: 10, GuessMinPoints: 50}, db.ScoringRules{}, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		suite.Run(tc.description, func() {
+			if tc.expectedStatusCode == http.StatusCreated {
+				suite.expectFreeGameIds()
+				suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, mock.Anything, tc.expectedRules).Return(nil).Once()
+				suite.dbMock.On("GetGameById", mock.Anything).Return(&db.Game{PlayerCount: 1, ScoringRules: tc.expectedRules})
+				suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
+				suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
+			}
+			createGameRequestBody, err := json.Marshal(parser.CreateGameRequest{
+				Player:         "rookie",
+				MaxPlayerCount: 5,
+				TotalRounds:    4,
+				Scoring:        tc.scoring,
+			})
+			suite.Nil(err, "Failed to create CreateGame request body")
+			resp, err := http.Post(url, "application/json", bytes.NewBuffer(createGameRequestBody))
+			suite.Nil(err, "Failed to execute CreateGame api call")
+			suite.Equal(tc.expectedStatusCode, resp.StatusCode)
+		})
+	}
+}
+
 func (suite *GameServerTestSuite) TestStartGame() {
 	tests := []struct {
 		description        string
@@ -174,7 +230,9 @@ func (suite *GameServerTestSuite) TestStartGame() {
 	for i, test := range tests {
 		suite.Run(test.description, func() {
 			mockGameObject := db.Game{
-				GameId: "xxxxxx",
+				GameId:       "xxxxxx",
+				CurrentRound: 1,
+				TotalRounds:  1,
 			}
 			mockPlayerObject := db.Player{
 				Name:      "Player1",
@@ -182,14 +240,21 @@ func (suite *GameServerTestSuite) TestStartGame() {
 				IsAdmin:   test.isAdmin,
 				AuthToken: fmt.Sprintf("dummy-token-%d", i),
 			}
-			suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken).Return(&mockPlayerObject)
+			suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken, mock.Anything).Return(&mockPlayerObject)
 			suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
 			suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{mockPlayerObject}, nil)
-			fakeGameState := state.InitGameState(mockGameObject.GameId, suite.dbMock)
+			suite.dbMock.On("RefreshToken", mockGameObject.GameId, mockPlayerObject.Name, mock.Anything).Return(nil)
+			fakeGameState := state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New())
+			defer fakeGameState.Close()
 			suite.stateMock.On("GetGameState", mock.Anything).Return(fakeGameState, nil)
-			url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/game/%s/start", mockGameObject.GameId)
 			header := http.Header{}
 			header.Add("Cookie", fmt.Sprintf("session-token=%s", mockPlayerObject.AuthToken))
+			// Only players with a connection are given a turn
+			connectURL := strings.ReplaceAll(suite.server.URL, "http:", "ws:") + HTTP_API_V1_PREFIX + fmt.Sprintf("/connect/game/%s", mockGameObject.GameId)
+			conn, _, err := websocket.DefaultDialer.Dial(connectURL, header)
+			suite.Nil(err, "Failed to establish websocket connection")
+			defer conn.Close()
+			url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/game/%s/start", mockGameObject.GameId)
 			req, err := http.NewRequest("POST", url, nil)
 			suite.Nil(err, "Failed to prepare StartGame request")
 			req.Header = header
@@ -220,7 +285,7 @@ func (suite *GameServerTestSuite) TestPlayersJoin() {
 	suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
 	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
 	suite.dbMock.On("AddPlayerToGame", mockGameObject.GameId, joiningPlayerName, mock.Anything).Return(nil)
-	suite.stateMock.On("GetGameState", mock.Anything).Return(state.InitGameState(mockGameObject.GameId, suite.dbMock), nil)
+	suite.stateMock.On("GetGameState", mock.Anything).Return(state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New()), nil)
 	url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/game/%s", mockGameObject.GameId)
 	join_request, _ := json.Marshal(parser.JoinGameRequest{Player: joiningPlayerName})
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(join_request))
@@ -235,6 +300,15 @@ func (suite *GameServerTestSuite) TestPlayersJoin() {
 	assertCookieValid(suite, resp)
 }
 
+func (suite *GameServerTestSuite) TestPlayersJoinWithReservedName() {
+	url := suite.server.URL + HTTP_API_V1_PREFIX + "/game/xxxxxx"
+	joinRequest, err := json.Marshal(parser.JoinGameRequest{Player: SELF_PLAYER_NAME})
+	suite.Nil(err, "Failed to create JoinGame request body")
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(joinRequest))
+	suite.Nil(err, "Failed to send JoinGameRequest")
+	suite.Equal(http.StatusBadRequest, resp.StatusCode, "A player called me could never be kicked")
+}
+
 func (suite *GameServerTestSuite) TestPlayersJoinCapacityFull() {
 	mockGameObject := db.Game{
 		GameId:       "xxxxxx",
@@ -263,16 +337,19 @@ func (suite *GameServerTestSuite) TestPlayerJoin() {
 		IsAdmin:   true,
 		AuthToken: "dummy-token",
 	}
-	suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken).Return(&mockPlayerObject)
+	suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken, mock.Anything).Return(&mockPlayerObject)
+	suite.dbMock.On("RefreshToken", mockGameObject.GameId, mockPlayerObject.Name, mock.Anything).Return(nil)
 	suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
 	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
-	suite.stateMock.On("GetGameState", mock.Anything).Return(state.InitGameState(mockGameObject.GameId, suite.dbMock), nil)
+	suite.stateMock.On("GetGameState", mock.Anything).Return(state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New()), nil)
 	url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/connect/game/%s", mockGameObject.GameId)
 	url = strings.ReplaceAll(url, "http:", "ws:")
 	header := http.Header{}
 	header.Add("Cookie", "session-token=dummy-token")
-	_, _, err := websocket.DefaultDialer.Dial(url, header)
+	_, resp, err := websocket.DefaultDialer.Dial(url, header)
 	suite.Nil(err, "Failed to establish websocket connection")
+	// Connecting keeps the session alive
+	assertCookieValid(suite, resp)
 	// body := parser.GamePlayerInput{
 	// 	Xcoord: 120,
 	// 	Ycoord: 120,
@@ -286,3 +363,472 @@ func (suite *GameServerTestSuite) TestPlayerJoin() {
 func (suite *GameServerTestSuite) TestBadPlayerInput() {
 
 }
+
+func (suite *GameServerTestSuite) TestCreateNewGameWithHints() {
+	url := suite.server.URL + HTTP_API_V1_PREFIX + "/game"
+	none, some, tooMany := uint8(0), uint8(3), uint8(10)
+	tests := []struct {
+		description   string
+		hints         *uint8
+		expectedHints uint8
+	}{
+		{"Test default hint count", nil, state.DEFAULT_HINT_COUNT},
+		{"Test hints turned off", &none, 0},
+		{"Test custom hint count", &some, 3},
+		// Like players and rounds, too many hints are capped rather than rejected
+		{"Test hint count exceeding maximum", &tooMany, state.MAX_HINT_COUNT},
+	}
+	for _, tc := range tests {
+		suite.Run(tc.description, func() {
+			suite.expectFreeGameIds()
+			suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, tc.expectedHints, mock.Anything).Return(nil).Once()
+			suite.dbMock.On("GetGameById", mock.Anything).Return(&db.Game{PlayerCount: 1, Hints: tc.expectedHints})
+			suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
+			suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
+			createGameRequestBody, err := json.Marshal(parser.CreateGameRequest{Player: "rookie", Hints: tc.hints})
+			suite.Nil(err, "Failed to create CreateGame request body")
+			resp, err := http.Post(url, "application/json", bytes.NewBuffer(createGameRequestBody))
+			suite.Nil(err, "Failed to execute CreateGame api call")
+			suite.Equal(http.StatusCreated, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *GameServerTestSuite) TestRematch() {
+	tests := []struct {
+		description        string
+		isAdmin            bool
+		finished           bool
+		expectedStatusCode int
+	}{
+		{"Test admin can start a rematch of a finished game", true, true, http.StatusOK},
+		{"Test admin can't rematch a game that hasn't finished", true, false, http.StatusConflict},
+		{"Test non-admin can't start a rematch", false, true, http.StatusForbidden},
+	}
+	for i, test := range tests {
+		suite.Run(test.description, func() {
+			// A game without rounds finishes as soon as it is started
+			mockGameObject := db.Game{GameId: fmt.Sprintf("rematch%c", 'a'+i), CurrentRound: 1}
+			mockPlayerObject := db.Player{
+				Name:      "Player1",
+				GameId:    mockGameObject.GameId,
+				IsAdmin:   test.isAdmin,
+				AuthToken: fmt.Sprintf("dummy-token-%d", i),
+			}
+			suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken, mock.Anything).Return(&mockPlayerObject)
+			suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
+			suite.dbMock.On("GetGamePlayers", mockGameObject.GameId).Return([]db.Player{mockPlayerObject}, nil)
+			suite.dbMock.On("GetGameScores", mockGameObject.GameId).Return([]db.Score{{Player: "Player1"}}, nil).Maybe()
+			suite.dbMock.On("ResetScores", mockGameObject.GameId).Return(nil).Maybe()
+			fakeGameState := state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New())
+			if test.finished {
+				suite.Nil(fakeGameState.Start())
+				suite.Eventually(func() bool { return fakeGameState.GetState() == state.FINISHED }, time.Second, 10*time.Millisecond)
+			}
+			stateBefore := fakeGameState.GetState()
+			suite.stateMock.On("GetGameState", mockGameObject.GameId).Return(fakeGameState, nil).Maybe()
+			url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/game/%s/rematch", mockGameObject.GameId)
+			req, err := http.NewRequest("POST", url, nil)
+			suite.Nil(err, "Failed to prepare Rematch request")
+			req.Header.Add("Cookie", fmt.Sprintf("session-token=%s", mockPlayerObject.AuthToken))
+			response, err := http.DefaultClient.Do(req)
+			suite.Nil(err, "Failed to send Rematch request")
+			suite.Equal(test.expectedStatusCode, response.StatusCode)
+			if test.expectedStatusCode == http.StatusOK {
+				suite.Equal(state.CREATED, fakeGameState.GetState())
+			} else {
+				suite.Equal(stateBefore, fakeGameState.GetState())
+			}
+		})
+	}
+}
+
+func (suite *GameServerTestSuite) TestLeaveAndKickPlayer() {
+	tests := []struct {
+		description        string
+		isAdmin            bool
+		path               string
+		removedPlayer      string
+		expectedStatusCode int
+	}{
+		{"Test player can leave the game", false, "players/me", "Player1", http.StatusNoContent},
+		{"Test admin can kick a player", true, "players/Player2", "Player2", http.StatusNoContent},
+		{"Test non-admin can't kick a player", false, "players/Player2", "", http.StatusForbidden},
+		{"Test kicking a player who isn't in the game", true, "players/Player3", "", http.StatusNotFound},
+	}
+	for i, test := range tests {
+		suite.Run(test.description, func() {
+			mockGameObject := db.Game{GameId: fmt.Sprintf("leave%c", 'a'+i), CurrentRound: 1, TotalRounds: 1}
+			mockPlayerObject := db.Player{
+				Name:      "Player1",
+				GameId:    mockGameObject.GameId,
+				IsAdmin:   test.isAdmin,
+				AuthToken: fmt.Sprintf("dummy-token-%d", i),
+			}
+			otherPlayer := db.Player{Name: "Player2", GameId: mockGameObject.GameId}
+			suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, mockPlayerObject.AuthToken, mock.Anything).Return(&mockPlayerObject)
+			suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
+			suite.dbMock.On("GetGamePlayers", mockGameObject.GameId).Return([]db.Player{mockPlayerObject, otherPlayer}, nil)
+			if len(test.removedPlayer) != 0 {
+				suite.dbMock.On("DeletePlayer", mockGameObject.GameId, test.removedPlayer).Return(nil).Once()
+			}
+			fakeGameState := state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New())
+			suite.stateMock.On("GetGameState", mockGameObject.GameId).Return(fakeGameState, nil).Maybe()
+			url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/game/%s/%s", mockGameObject.GameId, test.path)
+			req, err := http.NewRequest("DELETE", url, nil)
+			suite.Nil(err, "Failed to prepare DELETE request")
+			req.Header.Add("Cookie", fmt.Sprintf("session-token=%s", mockPlayerObject.AuthToken))
+			response, err := http.DefaultClient.Do(req)
+			suite.Nil(err, "Failed to send DELETE request")
+			suite.Equal(test.expectedStatusCode, response.StatusCode)
+			if len(test.removedPlayer) != 0 {
+				suite.False(fakeGameState.HasPlayer(test.removedPlayer))
+			}
+		})
+	}
+}
+
+func (suite *GameServerTestSuite) TestUnauthorizedRequests() {
+	suite.dbMock.On("GetGamePlayerByToken", "xxxxxx", "expired-token", mock.Anything).Return(nil)
+	tests := []struct {
+		description string
+		method      string
+		path        string
+		cookie      string
+	}{
+		{"Test starting a game without a session", "POST", "/game/xxxxxx/start", ""},
+		{"Test starting a game with an unknown or expired session", "POST", "/game/xxxxxx/start", "session-token=expired-token"},
+		{"Test kicking a player with an unknown or expired session", "DELETE", "/game/xxxxxx/players/bob", "session-token=expired-token"},
+		{"Test connecting with an unknown or expired session", "GET", "/connect/game/xxxxxx", "session-token=expired-token"},
+		{"Test refreshing an unknown or expired session", "POST", "/connect/game/xxxxxx/session", "session-token=expired-token"},
+	}
+	for _, test := range tests {
+		suite.Run(test.description, func() {
+			req, err := http.NewRequest(test.method, suite.server.URL+HTTP_API_V1_PREFIX+test.path, nil)
+			suite.Nil(err, "Failed to prepare request")
+			if len(test.cookie) != 0 {
+				req.Header.Add("Cookie", test.cookie)
+			}
+			response, err := http.DefaultClient.Do(req)
+			suite.Nil(err, "Failed to send request")
+			suite.Equal(http.StatusUnauthorized, response.StatusCode)
+		})
+	}
+}
+
+func (suite *GameServerTestSuite) TestRefreshSession() {
+	mockPlayerObject := db.Player{Name: "Player1", GameId: "xxxxxx", AuthToken: "dummy-token"}
+	suite.dbMock.On("GetGamePlayerByToken", "xxxxxx", "dummy-token", mock.Anything).Return(&mockPlayerObject)
+	suite.dbMock.On("RefreshToken", "xxxxxx", "Player1", mock.MatchedBy(func(expiresAt time.Time) bool {
+		return timeAlmostEqual(time.Now().Add(SESSION_TOKEN_TTL), expiresAt, time.Minute)
+	})).Return(nil).Once()
+	req, err := http.NewRequest("POST", suite.server.URL+HTTP_API_V1_PREFIX+"/connect/game/xxxxxx/session", nil)
+	suite.Nil(err, "Failed to prepare RefreshSession request")
+	req.Header.Add("Cookie", "session-token=dummy-token")
+	response, err := http.DefaultClient.Do(req)
+	suite.Nil(err, "Failed to send RefreshSession request")
+	suite.Equal(http.StatusNoContent, response.StatusCode)
+	assertCookieValid(suite, response)
+	suite.Equal("dummy-token", response.Cookies()[0].Value, "Refreshing must keep the same token")
+}
+
+func (suite *GameServerTestSuite) TestBearerAuthentication() {
+	mockPlayerObject := db.Player{Name: "Player1", GameId: "xxxxxx", AuthToken: "dummy-token"}
+	suite.dbMock.On("GetGamePlayerByToken", "xxxxxx", "dummy-token", mock.Anything).Return(&mockPlayerObject).Maybe()
+	suite.dbMock.On("RefreshToken", "xxxxxx", "Player1", mock.Anything).Return(nil).Maybe()
+	tests := []struct {
+		description        string
+		authScheme         string
+		header             string
+		value              string
+		expectedStatusCode int
+		expectCookie       bool
+	}{
+		{"Test bearer token with the default scheme", "", "Authorization", "Bearer dummy-token", http.StatusNoContent, true},
+		{"Test bearer token with the bearer scheme", AUTH_BEARER, "Authorization", "Bearer dummy-token", http.StatusNoContent, false},
+		{"Test bearer token with the cookie scheme", AUTH_COOKIE, "Authorization", "Bearer dummy-token", http.StatusUnauthorized, false},
+		{"Test cookie with the bearer scheme", AUTH_BEARER, "Cookie", "session-token=dummy-token", http.StatusUnauthorized, false},
+		{"Test cookie with the cookie scheme", AUTH_COOKIE, "Cookie", "session-token=dummy-token", http.StatusNoContent, true},
+	}
+	for _, test := range tests {
+		suite.Run(test.description, func() {
+			gs := CreateMockGameServer(suite.T(), suite.dbMock, suite.stateMock)
+			gs.AuthScheme = test.authScheme
+			server := httptest.NewServer(gs.Router)
+			defer server.Close()
+			req, err := http.NewRequest("POST", server.URL+HTTP_API_V1_PREFIX+"/connect/game/xxxxxx/session", nil)
+			suite.Nil(err, "Failed to prepare RefreshSession request")
+			req.Header.Add(test.header, test.value)
+			response, err := http.DefaultClient.Do(req)
+			suite.Nil(err, "Failed to send RefreshSession request")
+			suite.Equal(test.expectedStatusCode, response.StatusCode)
+			if test.expectCookie {
+				assertCookieValid(suite, response)
+			} else {
+				suite.Empty(response.Cookies(), "No cookie expected")
+			}
+		})
+	}
+}
+
+func (suite *GameServerTestSuite) TestConnectWithTokenSubprotocol() {
+	mockGameObject := db.Game{GameId: "yyyyyy"}
+	mockPlayerObject := db.Player{Name: "Player1", GameId: mockGameObject.GameId, IsAdmin: true, AuthToken: "dummy-token"}
+	suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, "dummy-token", mock.Anything).Return(&mockPlayerObject)
+	suite.dbMock.On("RefreshToken", mockGameObject.GameId, mockPlayerObject.Name, mock.Anything).Return(nil)
+	suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
+	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
+	suite.stateMock.On("GetGameState", mock.Anything).Return(state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), clock.New()), nil)
+	url := suite.server.URL + HTTP_API_V1_PREFIX + fmt.Sprintf("/connect/game/%s", mockGameObject.GameId)
+	url = strings.ReplaceAll(url, "http:", "ws:")
+	dialer := websocket.Dialer{Subprotocols: []string{WEBSOCKET_PROTOCOL, TOKEN_PROTOCOL_PREFIX + "dummy-token"}}
+	conn, _, err := dialer.Dial(url, nil)
+	suite.Nil(err, "Failed to establish websocket connection")
+	defer conn.Close()
+	suite.Equal(WEBSOCKET_PROTOCOL, conn.Subprotocol(), "Server must not echo the token protocol")
+}
+
+func (suite *GameServerTestSuite) TestCrossOriginRequests() {
+	gs := CreateMockGameServer(suite.T(), suite.dbMock, suite.stateMock)
+	origins, err := NewOriginPolicy("https://doodle.example.com")
+	suite.Nil(err, "Failed to create origin policy")
+	gs.Origins = origins
+	server := httptest.NewServer(gs.Router)
+	defer server.Close()
+	suite.Run("Test preflight from an allowed origin", func() {
+		req, err := http.NewRequest("OPTIONS", server.URL+HTTP_API_V1_PREFIX+"/game/xxxxxx/start", nil)
+		suite.Nil(err, "Failed to prepare preflight request")
+		req.Header.Set("Origin", "https://doodle.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		response, err := http.DefaultClient.Do(req)
+		suite.Nil(err, "Failed to send preflight request")
+		suite.Equal(http.StatusNoContent, response.StatusCode)
+		suite.Equal("https://doodle.example.com", response.Header.Get("Access-Control-Allow-Origin"))
+		suite.Equal("true", response.Header.Get("Access-Control-Allow-Credentials"))
+		suite.Contains(response.Header.Get("Access-Control-Allow-Methods"), "POST")
+		suite.Contains(response.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+	})
+	suite.Run("Test request from an allowed origin", func() {
+		req, err := http.NewRequest("POST", server.URL+HTTP_API_V1_PREFIX+"/game/xxxxxx/start", nil)
+		suite.Nil(err, "Failed to prepare request")
+		req.Header.Set("Origin", "https://doodle.example.com")
+		response, err := http.DefaultClient.Do(req)
+		suite.Nil(err, "Failed to send request")
+		suite.Equal(http.StatusUnauthorized, response.StatusCode)
+		suite.Equal("https://doodle.example.com", response.Header.Get("Access-Control-Allow-Origin"))
+	})
+	suite.Run("Test request from another origin", func() {
+		req, err := http.NewRequest("POST", server.URL+HTTP_API_V1_PREFIX+"/game/xxxxxx/start", nil)
+		suite.Nil(err, "Failed to prepare request")
+		req.Header.Set("Origin", "https://evil.com")
+		response, err := http.DefaultClient.Do(req)
+		suite.Nil(err, "Failed to send request")
+		suite.Equal(http.StatusForbidden, response.StatusCode)
+		suite.Empty(response.Header.Get("Access-Control-Allow-Origin"))
+	})
+	suite.Run("Test websocket upgrade from another origin", func() {
+		suite.dbMock.On("GetGamePlayerByToken", "xxxxxx", "dummy-token", mock.Anything).Return(&db.Player{Name: "Player1", GameId: "xxxxxx"}).Once()
+		suite.dbMock.On("RefreshToken", "xxxxxx", "Player1", mock.Anything).Return(nil).Once()
+		url := strings.ReplaceAll(server.URL+HTTP_API_V1_PREFIX+"/connect/game/xxxxxx", "http:", "ws:")
+		header := http.Header{}
+		header.Add("Cookie", "session-token=dummy-token")
+		header.Add("Origin", "https://evil.com")
+		_, resp, err := websocket.DefaultDialer.Dial(url, header)
+		suite.NotNil(err, "Upgrade from another origin must fail")
+		suite.Equal(http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func (suite *GameServerTestSuite) TestCreateNewGameRetriesTakenIds() {
+	takenInStore := ""
+	suite.stateMock.On("GetGameState", mock.Anything).Run(func(args mock.Arguments) {
+		takenInStore = args.String(0)
+	}).Return(&state.GameState{}, nil).Once()
+	suite.expectFreeGameIds()
+	attempted := []string{}
+	createGame := func(args mock.Arguments) { attempted = append(attempted, args.String(0)) }
+	suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(createGame).Return(db.ErrGameExists).Once()
+	suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(createGame).Return(nil).Once()
+	suite.dbMock.On("GetGameById", mock.Anything).Return(&db.Game{PlayerCount: 1})
+	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
+	suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
+	body, err := json.Marshal(map[string]any{"player": "rookie", "max_players": 5, "total_rounds": 4})
+	suite.Nil(err, "Failed to create CreateGame request body")
+	resp, err := http.Post(suite.server.URL+HTTP_API_V1_PREFIX+"/game", "application/json", bytes.NewBuffer(body))
+	suite.Nil(err, "Failed to execute CreateGame api call")
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	respBody, err := ReadResponseBody(resp)
+	suite.Nil(err, "Failed to read CreateGame response body")
+	createGameResponse := parser.CreateGameResponse{}
+	suite.Nil(json.Unmarshal(respBody, &createGameResponse), "Failed to deserialize CreateGame response body")
+	suite.Len(attempted, 2, "A taken id must be retried")
+	suite.NotContains(attempted, takenInStore, "Ids with a game state must not be used")
+	suite.NotEqual(attempted[0], attempted[1])
+	suite.Equal(attempted[1], createGameResponse.GameId)
+}
+
+func (suite *GameServerTestSuite) TestCreateNewGameGivesUpAfterConfiguredAttempts() {
+	gs := CreateMockGameServer(suite.T(), suite.dbMock, suite.stateMock)
+	gameIds, err := gameid.New(gameid.Config{MaxAttempts: 2})
+	suite.Nil(err, "Failed to create game id allocator")
+	gs.GameIds = gameIds
+	server := httptest.NewServer(gs.Router)
+	defer server.Close()
+	suite.expectFreeGameIds()
+	suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(db.ErrGameExists).Times(2)
+	body, err := json.Marshal(map[string]any{"player": "rookie", "max_players": 5, "total_rounds": 4})
+	suite.Nil(err, "Failed to create CreateGame request body")
+	resp, err := http.Post(server.URL+HTTP_API_V1_PREFIX+"/game", "application/json", bytes.NewBuffer(body))
+	suite.Nil(err, "Failed to execute CreateGame api call")
+	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	suite.dbMock.AssertNumberOfCalls(suite.T(), "CreateNewGame", 2)
+}
+
+func (suite *GameServerTestSuite) TestCreateNewGameWithFriendlyIds() {
+	gs := CreateMockGameServer(suite.T(), suite.dbMock, suite.stateMock)
+	gameIds, err := gameid.New(gameid.Config{Style: gameid.STYLE_FRIENDLY})
+	suite.Nil(err, "Failed to create game id allocator")
+	gs.GameIds = gameIds
+	server := httptest.NewServer(gs.Router)
+	defer server.Close()
+	suite.expectFreeGameIds()
+	suite.dbMock.On("GetGameById", "brave-otter-42").Return(nil).Once()
+	suite.dbMock.On("CreateNewGame", mock.Anything, "rookie", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	suite.dbMock.On("GetGameById", mock.Anything).Return(&db.Game{PlayerCount: 1})
+	suite.dbMock.On("GetGamePlayers", mock.Anything).Return([]db.Player{}, nil)
+	suite.stateMock.On("SetGameState", mock.Anything, mock.Anything).Return(nil)
+	body, err := json.Marshal(map[string]any{"player": "rookie", "max_players": 5, "total_rounds": 4})
+	suite.Nil(err, "Failed to create CreateGame request body")
+	resp, err := http.Post(server.URL+HTTP_API_V1_PREFIX+"/game", "application/json", bytes.NewBuffer(body))
+	suite.Nil(err, "Failed to execute CreateGame api call")
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	respBody, err := ReadResponseBody(resp)
+	suite.Nil(err, "Failed to read CreateGame response body")
+	createGameResponse := parser.CreateGameResponse{}
+	suite.Nil(json.Unmarshal(respBody, &createGameResponse), "Failed to deserialize CreateGame response body")
+	suite.Regexp(`^[a-z]+-[a-z]+-[0-9]{2}$`, createGameResponse.GameId)
+	// Friendly ids are routable like any other
+	join, err := json.Marshal(parser.JoinGameRequest{Player: "player1"})
+	suite.Nil(err, "Failed to create JoinGame request body")
+	resp, err = http.Post(server.URL+HTTP_API_V1_PREFIX+"/game/brave-otter-42", "application/json", bytes.NewBuffer(join))
+	suite.Nil(err, "Failed to execute JoinGame api call")
+	suite.Equal(http.StatusBadRequest, resp.StatusCode, "Unknown game expected, not an unmatched route")
+}
+
+func (suite *GameServerTestSuite) TestSimulatedGameWithFakeClock() {
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	gs := CreateMockGameServer(suite.T(), suite.dbMock, suite.stateMock)
+	gs.Clock = fakeClock
+	server := httptest.NewServer(gs.Router)
+	defer server.Close()
+
+	mockGameObject := db.Game{GameId: "simulated", CurrentRound: 1, TotalRounds: 3, ScoringRules: state.DEFAULT_SCORING_RULES}
+	alice := db.Player{Name: "alice", GameId: mockGameObject.GameId, IsAdmin: true, AuthToken: "alice-token"}
+	bob := db.Player{Name: "bob", GameId: mockGameObject.GameId, AuthToken: "bob-token"}
+	suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, alice.AuthToken, mock.Anything).Return(&alice)
+	suite.dbMock.On("GetGamePlayerByToken", mockGameObject.GameId, bob.AuthToken, mock.Anything).Return(&bob)
+	suite.dbMock.On("RefreshToken", mockGameObject.GameId, mock.Anything, fakeClock.Now().Add(SESSION_TOKEN_TTL)).Return(nil)
+	suite.dbMock.On("GetGameById", mockGameObject.GameId).Return(&mockGameObject)
+	suite.dbMock.On("GetGamePlayers", mockGameObject.GameId).Return([]db.Player{alice, bob}, nil)
+	suite.dbMock.On("GetGameScores", mockGameObject.GameId).Return([]db.Score{{Player: "alice"}, {Player: "bob"}}, nil)
+	suite.dbMock.On("UpdatePlayerScore", mockGameObject.GameId, mock.Anything, mock.Anything).Return(nil)
+	game := state.InitGameState(mockGameObject.GameId, suite.dbMock, words.NewStaticWordBank(words.DEFAULT_WORDS), fakeClock)
+	suite.stateMock.On("GetGameState", mockGameObject.GameId).Return(game, nil)
+
+	connect := func(token string) *websocket.Conn {
+		url := strings.ReplaceAll(server.URL+HTTP_API_V1_PREFIX+"/connect/game/"+mockGameObject.GameId, "http:", "ws:")
+		header := http.Header{}
+		header.Add("Cookie", fmt.Sprintf("session-token=%s", token))
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		suite.Require().Nil(err, "Failed to establish websocket connection")
+		return conn
+	}
+	aliceConn := connect(alice.AuthToken)
+	defer aliceConn.Close()
+	bobConn := connect(bob.AuthToken)
+	defer bobConn.Close()
+	// bob only guesses, his events are drained so that he keeps up
+	go func() {
+		for {
+			if _, _, err := bobConn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	req, err := http.NewRequest("POST", server.URL+HTTP_API_V1_PREFIX+"/game/simulated/start", nil)
+	suite.Require().Nil(err)
+	req.Header.Add("Cookie", fmt.Sprintf("session-token=%s", alice.AuthToken))
+	response, err := http.DefaultClient.Do(req)
+	suite.Require().Nil(err, "Failed to send StartGame request")
+	suite.Require().Equal(http.StatusOK, response.StatusCode)
+
+	// Every timer is set before the event announcing it goes out, so the clock
+	// is moved past a deadline as soon as its event arrives
+	started := fakeClock.Now()
+	turns, roundEnds := []string{}, 0
+	for {
+		suite.Require().Nil(aliceConn.SetReadDeadline(time.Now().Add(2 * time.Second)))
+		_, data, err := aliceConn.ReadMessage()
+		suite.Require().Nil(err, "Failed to read game event")
+		event, err := parser.Decode(data)
+		suite.Require().Nil(err)
+		switch event.Type {
+		case parser.MSG_WORD_CHOICES, parser.MSG_CHOOSING_WORD:
+			fakeClock.Advance(state.WORD_CHOICE_DURATION)
+		case parser.MSG_WORD_SELECTED:
+			// bob gets alice's word right away and the turn ends with him
+			selected := parser.WordSelectedEvent{}
+			suite.Require().Nil(json.Unmarshal(event.Payload, &selected))
+			suite.True(selected.AutoPicked)
+			guess, err := parser.Encode(parser.MSG_CHAT, 0, parser.ChatInput{Text: selected.Word})
+			suite.Require().Nil(err)
+			suite.Require().Nil(bobConn.WriteMessage(websocket.TextMessage, guess))
+		case parser.MSG_TURN_START:
+			turn := parser.TurnStartEvent{}
+			suite.Require().Nil(json.Unmarshal(event.Payload, &turn))
+			turns = append(turns, turn.Drawer)
+			if turn.Drawer == "bob" {
+				fakeClock.Advance(state.TURN_DURATION)
+			}
+		case parser.MSG_SCORE_UPDATE:
+			update := parser.ScoreUpdateEvent{}
+			suite.Require().Nil(json.Unmarshal(event.Payload, &update))
+			if turns[len(turns)-1] == "alice" {
+				// The clock stood still, bob guessed with the whole turn left
+				suite.Equal(map[string]uint{"bob": 100, "alice": 25}, update.Deltas)
+			} else {
+				suite.Empty(update.Deltas)
+			}
+		case parser.MSG_ROUND_END:
+			roundEnds += 1
+			fakeClock.Advance(state.ROUND_END_DURATION)
+		}
+		if event.Type == parser.MSG_GAME_OVER {
+			break
+		}
+	}
+	suite.Equal([]string{"alice", "bob", "alice", "bob", "alice", "bob"}, turns)
+	suite.Equal(2, roundEnds)
+	suite.Equal(state.FINISHED, game.GetState())
+	suite.Equal(6*state.WORD_CHOICE_DURATION+3*state.TURN_DURATION+2*state.ROUND_END_DURATION, fakeClock.Now().Sub(started))
+	suite.Equal(0, fakeClock.Pending(), "Finished games must not leave timers behind")
+	// Closed before the players hang up, for nobody to take over as admin
+	game.Close()
+}
+
+func (suite *GameServerTestSuite) TestDurationFromEnv() {
+	suite.T().Setenv("DOODLE_RECONNECT_GRACE", "")
+	grace, err := durationFromEnv("DOODLE_RECONNECT_GRACE", state.RECONNECT_GRACE_PERIOD)
+	suite.Nil(err)
+	suite.Equal(state.RECONNECT_GRACE_PERIOD, grace, "Unset durations fall back to their default")
+	suite.T().Setenv("DOODLE_RECONNECT_GRACE", "2m")
+	grace, err = durationFromEnv("DOODLE_RECONNECT_GRACE", state.RECONNECT_GRACE_PERIOD)
+	suite.Nil(err)
+	suite.Equal(2*time.Minute, grace)
+	for _, invalid := range []string{"-30s", "0s", "soon"} {
+		suite.T().Setenv("DOODLE_RECONNECT_GRACE", invalid)
+		_, err = durationFromEnv("DOODLE_RECONNECT_GRACE", state.RECONNECT_GRACE_PERIOD)
+		suite.NotNil(err, invalid)
+	}
+}
@@ -0,0 +1,120 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/parser"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// MAX_STROKE_LENGTH caps the points of a stroke over all of its batches
+const MAX_STROKE_LENGTH = 8 * parser.MAX_STROKE_POINTS
+
+// MAX_DRAWING_POINTS caps the points drawn in a turn, undone strokes included
+const MAX_DRAWING_POINTS = 64 * parser.MAX_STROKE_POINTS
+
+// strokeLog is the drawing of the ongoing turn. Batches streamed for the same
+// stroke are merged as they arrive, so the log holds one entry per stroke.
+// Undone strokes are moved to a redo stack until a new stroke is drawn
+type strokeLog struct {
+	strokes []parser.Stroke
+	index   map[uint32]int
+	undone  []parser.Stroke
+	// points counts the points of strokes and undone
+	points int
+}
+
+func newStrokeLog() *strokeLog {
+	return &strokeLog{strokes: []parser.Stroke{}, index: make(map[uint32]int), undone: []parser.Stroke{}}
+}
+
+// add draws stroke, or appends it to the stroke it continues. A batch has to be
+// drawn with the same tool, color, brush and canvas as the stroke it continues
+// so that players who join later see the drawing everyone else was sent
+func (l *strokeLog) add(stroke parser.Stroke) error {
+	if i, exists := l.index[stroke.StrokeId]; exists {
+		existing := &l.strokes[i]
+		if existing.Tool == parser.TOOL_FILL || stroke.Tool != existing.Tool || stroke.Color != existing.Color ||
+			stroke.BrushSize != existing.BrushSize || stroke.Canvas != existing.Canvas {
+			return fmt.Errorf("Batch does not continue stroke %d", stroke.StrokeId)
+		}
+		if len(existing.Points)+len(stroke.Points) > MAX_STROKE_LENGTH {
+			return fmt.Errorf("Stroke %d is longer than %d points", stroke.StrokeId, MAX_STROKE_LENGTH)
+		}
+		if err := l.reserve(len(stroke.Points), 0); err != nil {
+			return err
+		}
+		existing.Points = append(existing.Points, stroke.Points...)
+		return nil
+	}
+	if err := l.reserve(len(stroke.Points), pointsOf(l.undone)); err != nil {
+		return err
+	}
+	l.undone = []parser.Stroke{}
+	stroke.Points = slices.Clone(stroke.Points)
+	l.push(stroke)
+	return nil
+}
+
+// reserve makes room for count more points once freed have been dropped
+func (l *strokeLog) reserve(count, freed int) error {
+	if l.points-freed+count > MAX_DRAWING_POINTS {
+		return errors.New("Drawing has too many points")
+	}
+	l.points += count - freed
+	return nil
+}
+
+func pointsOf(strokes []parser.Stroke) int {
+	count := 0
+	for _, stroke := range strokes {
+		count += len(stroke.Points)
+	}
+	return count
+}
+
+func (l *strokeLog) push(stroke parser.Stroke) {
+	l.index[stroke.StrokeId] = len(l.strokes)
+	l.strokes = append(l.strokes, stroke)
+}
+
+// undo removes the latest stroke from the drawing
+func (l *strokeLog) undo() (parser.Stroke, bool) {
+	if len(l.strokes) == 0 {
+		return parser.Stroke{}, false
+	}
+	last := l.strokes[len(l.strokes)-1]
+	l.strokes = l.strokes[:len(l.strokes)-1]
+	delete(l.index, last.StrokeId)
+	l.undone = append(l.undone, last)
+	return last, true
+}
+
+// redo puts the most recently undone stroke back on the drawing
+func (l *strokeLog) redo() (parser.Stroke, bool) {
+	if len(l.undone) == 0 {
+		return parser.Stroke{}, false
+	}
+	stroke := l.undone[len(l.undone)-1]
+	l.undone = l.undone[:len(l.undone)-1]
+	l.push(stroke)
+	return stroke, true
+}
+
+// clear wipes the drawing along with its undo history
+func (l *strokeLog) clear() {
+	l.strokes = []parser.Stroke{}
+	l.index = make(map[uint32]int)
+	l.undone = []parser.Stroke{}
+	l.points = 0
+}
+
+// snapshot returns a copy of the drawing that is safe to hand out
+func (l *strokeLog) snapshot() []parser.Stroke {
+	strokes := make([]parser.Stroke, len(l.strokes))
+	for i, stroke := range l.strokes {
+		stroke.Points = slices.Clone(stroke.Points)
+		strokes[i] = stroke
+	}
+	return strokes
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strokeIds(strokes []parser.Stroke) []uint32 {
+	ids := []uint32{}
+	for _, stroke := range strokes {
+		ids = append(ids, stroke.StrokeId)
+	}
+	return ids
+}
+
+func TestStrokeLogUndoRedo(t *testing.T) {
+	log := newStrokeLog()
+	assert.Nil(t, log.add(testStroke(1)))
+	assert.Nil(t, log.add(testStroke(2)))
+	assert.Nil(t, log.add(testStroke(3)))
+
+	undone, ok := log.undo()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), undone.StrokeId)
+	undone, _ = log.undo()
+	assert.Equal(t, uint32(2), undone.StrokeId)
+	assert.Equal(t, []uint32{1}, strokeIds(log.snapshot()))
+
+	redone, ok := log.redo()
+	assert.True(t, ok)
+	assert.Equal(t, testStroke(2), redone)
+	assert.Equal(t, []uint32{1, 2}, strokeIds(log.snapshot()))
+
+	// Drawing something new forgets about the strokes left to redo
+	assert.Nil(t, log.add(testStroke(4)))
+	_, ok = log.redo()
+	assert.False(t, ok)
+	assert.Equal(t, []uint32{1, 2, 4}, strokeIds(log.snapshot()))
+
+	log.clear()
+	assert.Empty(t, log.snapshot())
+	_, ok = log.undo()
+	assert.False(t, ok)
+	_, ok = log.redo()
+	assert.False(t, ok)
+}
+
+func TestStrokeLogContinuations(t *testing.T) {
+	log := newStrokeLog()
+	assert.Nil(t, log.add(testStroke(1)))
+	assert.Nil(t, log.add(testStroke(1)))
+	assert.Len(t, log.snapshot()[0].Points, 4)
+
+	recolored := testStroke(1)
+	recolored.Color = "#ff0000"
+	assert.NotNil(t, log.add(recolored), "A batch must be drawn like the stroke it continues")
+	erased := testStroke(1)
+	erased.Tool = parser.TOOL_ERASER
+	assert.NotNil(t, log.add(erased))
+	assert.Len(t, log.snapshot()[0].Points, 4)
+}
+
+func TestStrokeLogLimits(t *testing.T) {
+	log := newStrokeLog()
+	batch := testStroke(1)
+	batch.Points = make([]parser.Point, parser.MAX_STROKE_POINTS)
+	for added := 0; added < MAX_STROKE_LENGTH; added += parser.MAX_STROKE_POINTS {
+		assert.Nil(t, log.add(batch))
+	}
+	assert.NotNil(t, log.add(batch), "A stroke can't grow past MAX_STROKE_LENGTH")
+
+	for strokeId := uint32(2); log.points+parser.MAX_STROKE_POINTS <= MAX_DRAWING_POINTS; strokeId += 1 {
+		batch.StrokeId = strokeId
+		assert.Nil(t, log.add(batch))
+	}
+	batch.StrokeId = 1000
+	assert.NotNil(t, log.add(batch), "A turn's drawing can't grow past MAX_DRAWING_POINTS")
+	// Undone strokes still count until something new is drawn
+	_, ok := log.undo()
+	assert.True(t, ok)
+	assert.Nil(t, log.add(batch))
+
+	log.clear()
+	assert.Nil(t, log.add(batch))
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/logger"
+	"github.com/anchal00/doodle/internal/parser"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SEND_BUFFER_SIZE is how many messages may be queued up for a player before
+// they are considered too slow and get disconnected
+const SEND_BUFFER_SIZE = 64
+const WRITE_TIMEOUT = 10 * time.Second
+
+// playerConn owns a player's websocket. Websocket connections don't support
+// concurrent writers, so every write goes through a dedicated goroutine that
+// drains the send buffer
+type playerConn struct {
+	player string
+	conn   *websocket.Conn
+	// connectedAt decides who takes over as admin, longest connected first
+	connectedAt time.Time
+	send        chan []byte
+	done        chan struct{}
+	closeOnce   *sync.Once
+	log         logger.Logger
+}
+
+func newPlayerConn(player string, conn *websocket.Conn, log logger.Logger, clk clock.Clock) *playerConn {
+	// Oversized frames fail the connection instead of being read into memory
+	conn.SetReadLimit(parser.MAX_MESSAGE_SIZE)
+	pc := &playerConn{
+		player:      player,
+		conn:        conn,
+		connectedAt: clk.Now(),
+		send:        make(chan []byte, SEND_BUFFER_SIZE),
+		done:        make(chan struct{}),
+		closeOnce:   &sync.Once{},
+		log:         log,
+	}
+	go pc.writePump()
+	return pc
+}
+
+// enqueue queues message for delivery without blocking, it returns false when
+// the player's buffer is full or the connection has been closed
+func (p *playerConn) enqueue(message []byte) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+	select {
+	case p.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// closed tells if the connection has been closed
+func (p *playerConn) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the write pump and closes the websocket, dropping queued messages
+func (p *playerConn) close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
+
+func (p *playerConn) writePump() {
+	defer p.conn.Close()
+	for {
+		select {
+		case <-p.done:
+			// Write deadlines are checked by the network stack against the wall
+			// clock, not the game's
+			deadline := time.Now().Add(WRITE_TIMEOUT)
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			_ = p.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+			return
+		case message := <-p.send:
+			_ = p.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+			if err := p.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				p.log.Error(fmt.Sprintf("Failed to send message to player %s", p.player), err)
+				p.close()
+				return
+			}
+		}
+	}
+}
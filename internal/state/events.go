@@ -0,0 +1,195 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// EVENT_BUFFER_SIZE is how many events may wait for the game's goroutine
+// before players' readers and timers block on it
+const EVENT_BUFFER_SIZE = 64
+
+// ErrGameClosed is returned by a game's methods once it has been closed
+var ErrGameClosed = errors.New("Game has been closed")
+
+type timerKind int
+
+const (
+	// WORD_CHOICE_TIMER picks a word for a drawer who didn't choose in time
+	WORD_CHOICE_TIMER timerKind = iota
+	// HINT_TIMER reveals one more letter of the word
+	HINT_TIMER
+	// TURN_TIMER ends the turn at its deadline
+	TURN_TIMER
+	// ROUND_END_TIMER starts the next round once the scoreboard has been shown
+	ROUND_END_TIMER
+	// RECONNECT_TIMER removes a player who didn't come back in time
+	RECONNECT_TIMER
+	// KICK_VOTE_TIMER closes a vote that didn't pass in time
+	KICK_VOTE_TIMER
+	// SESSION_TIMER refreshes the session tokens of connected players
+	SESSION_TIMER
+	// RESUME_TIMER lets a restored game move on once its players had the time
+	// to reconnect
+	RESUME_TIMER
+)
+
+// inputEvent is a message read off a player's connection
+type inputEvent struct {
+	player string
+	data   []byte
+}
+
+// connectEvent attaches a new connection for player
+type connectEvent struct {
+	player string
+	conn   *websocket.Conn
+	done   chan struct{}
+}
+
+// disconnectEvent reports that the connection pc was read from has failed
+type disconnectEvent struct {
+	pc *playerConn
+}
+
+// removeEvent takes player out of the game for good
+type removeEvent struct {
+	player string
+	reason string
+	result chan error
+}
+
+// timerEvent is sent when one of the game's timers runs out. Timers of the game
+// loop belong to a phase and are ignored once the game has moved past it
+type timerEvent struct {
+	kind  timerKind
+	phase uint64
+	// player and absence identify the seat a RECONNECT_TIMER was set for,
+	// player and vote the vote a KICK_VOTE_TIMER was set for
+	player  string
+	absence *absence
+	vote    *kickVote
+}
+
+// callEvent runs fn on the game's goroutine, it is how the rest of the server
+// reads and drives the game
+type callEvent struct {
+	fn   func()
+	done chan struct{}
+}
+
+// absence is the seat kept for a player who lost their connection
+type absence struct {
+	timer clock.Timer
+}
+
+// run is the game's goroutine. It is the only one to touch the GameState's
+// fields, everything else talks to it through g.events. It returns once the
+// game has been closed, events still queued then are dropped
+func (g *GameState) run() {
+	for !g.closed {
+		switch ev := (<-g.events).(type) {
+		case inputEvent:
+			g.handleInput(ev.player, ev.data)
+		case connectEvent:
+			g.addConnection(ev.player, ev.conn)
+			close(ev.done)
+		case disconnectEvent:
+			g.connectionLost(ev.pc)
+		case removeEvent:
+			ev.result <- g.removePlayer(ev.player, ev.reason)
+		case timerEvent:
+			g.timerFired(ev)
+		case callEvent:
+			ev.fn()
+			close(ev.done)
+		}
+	}
+	close(g.quit)
+}
+
+// send hands ev over to the game's goroutine, it returns false without
+// blocking if the game has been closed
+func (g *GameState) send(ev any) bool {
+	select {
+	case g.events <- ev:
+		return true
+	case <-g.quit:
+		return false
+	}
+}
+
+// call runs fn on the game's goroutine and waits for it to return, fn is not
+// run at all if the game is closed first. It must not be used from the game's
+// goroutine itself
+func (g *GameState) call(fn func()) error {
+	done := make(chan struct{})
+	if !g.send(callEvent{fn: fn, done: done}) {
+		return ErrGameClosed
+	}
+	select {
+	case <-done:
+		return nil
+	case <-g.quit:
+		// done is closed before quit if fn did run
+		select {
+		case <-done:
+			return nil
+		default:
+			return ErrGameClosed
+		}
+	}
+}
+
+// after sends ev to the game's goroutine once d has passed
+func (g *GameState) after(d time.Duration, ev timerEvent) clock.Timer {
+	return g.clock.AfterFunc(d, func() { g.send(ev) })
+}
+
+// schedule sets a timer for the ongoing phase of the game loop
+func (g *GameState) schedule(d time.Duration, kind timerKind) {
+	g.phaseTimers = append(g.phaseTimers, g.after(d, timerEvent{kind: kind, phase: g.phase}))
+}
+
+// nextPhase moves the game loop on, cancelling the timers of the previous phase
+func (g *GameState) nextPhase() {
+	for _, timer := range g.phaseTimers {
+		timer.Stop()
+	}
+	g.phaseTimers = nil
+	g.phase += 1
+	g.held = false
+}
+
+func (g *GameState) timerFired(ev timerEvent) {
+	switch ev.kind {
+	case RECONNECT_TIMER:
+		g.reconnectTimedOut(ev.player, ev.absence)
+		return
+	case KICK_VOTE_TIMER:
+		g.expireKickVote(ev.player, ev.vote)
+		return
+	case SESSION_TIMER:
+		g.refreshSessions()
+		return
+	}
+	// A timer may fire just as its phase ends
+	if ev.phase != g.phase {
+		return
+	}
+	switch ev.kind {
+	case WORD_CHOICE_TIMER:
+		g.autoPickWord()
+	case HINT_TIMER:
+		g.revealHint()
+	case TURN_TIMER:
+		g.endTurn()
+	case ROUND_END_TIMER:
+		g.startRound()
+	case RESUME_TIMER:
+		g.resume()
+	}
+}
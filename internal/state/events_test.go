@@ -0,0 +1,124 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	dbMock "github.com/anchal00/doodle/internal/db/mocks"
+	"github.com/anchal00/doodle/internal/parser"
+	"github.com/anchal00/doodle/internal/words"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// GOROUTINES_PER_GAME are the game's own goroutine plus a reader and a writer
+// for each of its two players
+const GOROUTINES_PER_GAME = 1 + 2*2
+
+// gameFleet plays many games at once over a single websocket server
+type gameFleet struct {
+	server      *httptest.Server
+	serverConns chan *websocket.Conn
+	games       []*GameState
+	clients     [][]*websocket.Conn
+}
+
+func newGameFleet(t testing.TB, count int) *gameFleet {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	fleet := &gameFleet{serverConns: make(chan *websocket.Conn)}
+	fleet.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			fleet.serverConns <- conn
+		}
+	}))
+	t.Cleanup(fleet.server.Close)
+	for i := 0; i < count; i += 1 {
+		gameId := fmt.Sprintf("game%d", i)
+		repo := dbMock.NewRepository(t)
+		repo.On("GetGameById", gameId).Return(&db.Game{GameId: gameId, CurrentRound: 1, TotalRounds: 2, ScoringRules: DEFAULT_SCORING_RULES})
+		repo.On("GetGamePlayers", gameId).Return([]db.Player{{Name: "alice", GameId: gameId}, {Name: "bob", GameId: gameId}}, nil)
+		repo.On("GetGameScores", gameId).Return([]db.Score{{Player: "alice"}, {Player: "bob"}}, nil).Maybe()
+		repo.On("UpdatePlayerScore", gameId, mock.Anything, mock.Anything).Return(nil).Maybe()
+		gs := InitGameState(gameId, repo, words.NewStaticWordBank([]string{"apple", "banana", "cherry"}), clock.New())
+		gs.turnDuration = 20 * time.Millisecond
+		gs.wordChoiceDuration = 10 * time.Millisecond
+		gs.roundEndDuration = 10 * time.Millisecond
+		gs.reconnectGrace = time.Hour
+		clients := []*websocket.Conn{}
+		for _, player := range []string{"alice", "bob"} {
+			client, _, err := websocket.DefaultDialer.Dial(strings.ReplaceAll(fleet.server.URL, "http:", "ws:"), nil)
+			require.Nil(t, err, "Failed to dial test connection")
+			t.Cleanup(func() { client.Close() })
+			gs.AddConnection(player, <-fleet.serverConns)
+			clients = append(clients, client)
+		}
+		fleet.games = append(fleet.games, gs)
+		fleet.clients = append(fleet.clients, clients)
+	}
+	return fleet
+}
+
+// play starts every game and waits for all of them to be over
+func (f *gameFleet) play(t testing.TB) {
+	wg := sync.WaitGroup{}
+	for i, gs := range f.games {
+		require.Nil(t, gs.Start())
+		wg.Add(1)
+		go func(alice *websocket.Conn) {
+			defer wg.Done()
+			for {
+				_ = alice.SetReadDeadline(time.Now().Add(10 * time.Second))
+				_, data, err := alice.ReadMessage()
+				if err != nil {
+					t.Error("Failed to read game event", err)
+					return
+				}
+				event, err := parser.Decode(data)
+				if err == nil && event.Type == parser.MSG_GAME_OVER {
+					return
+				}
+			}
+		}(f.clients[i][0])
+	}
+	wg.Wait()
+}
+
+func TestGoroutinesStableAcrossGames(t *testing.T) {
+	const games = 50
+	before := runtime.NumGoroutine()
+	fleet := newGameFleet(t, games)
+	connected := runtime.NumGoroutine()
+	assert.LessOrEqual(t, connected-before, games*GOROUTINES_PER_GAME+5, "Each game and connection must start its goroutines once")
+
+	fleet.play(t)
+	for _, gs := range fleet.games {
+		assert.Equal(t, FINISHED, gs.GetState())
+	}
+	// Finished games keep their connections open and nothing else
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= connected+5 }, time.Second, 10*time.Millisecond)
+}
+
+func BenchmarkConcurrentGames(b *testing.B) {
+	const games = 200
+	for i := 0; i < b.N; i += 1 {
+		b.StopTimer()
+		before := runtime.NumGoroutine()
+		fleet := newGameFleet(b, games)
+		b.StartTimer()
+		fleet.play(b)
+		b.StopTimer()
+		b.ReportMetric(float64(runtime.NumGoroutine()-before)/games, "goroutines/game")
+		b.StartTimer()
+	}
+}
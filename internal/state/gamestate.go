@@ -1,12 +1,17 @@
 package state
 
 import (
+	"github.com/anchal00/doodle/internal/clock"
 	"github.com/anchal00/doodle/internal/db"
 	"github.com/anchal00/doodle/internal/logger"
-	"encoding/json"
+	"github.com/anchal00/doodle/internal/parser"
+	"github.com/anchal00/doodle/internal/words"
 	"errors"
 	"fmt"
-	"sync"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-set/v3"
@@ -15,120 +20,1000 @@ import (
 type state int
 
 const (
-    CREATED state = iota
-    STARTED
+	CREATED state = iota
+	STARTED
+	// ROUND_END is the pause between two rounds where players get to see the scoreboard
+	ROUND_END
+	FINISHED
 )
 
+const TURN_DURATION = 60 * time.Second
+const ROUND_END_DURATION = 5 * time.Second
+
+// RECONNECT_GRACE_PERIOD is how long a disconnected player keeps their seat
+const RECONNECT_GRACE_PERIOD = 30 * time.Second
+const WORD_CHOICE_DURATION = 15 * time.Second
+const WORD_CHOICE_COUNT = 3
+
+// GameState is owned by the game's goroutine, see run. Its fields must only be
+// touched from there, the exported methods hand their work over to it
 type GameState struct {
 	turnQueue    []string
 	gameId       string
-	connections  map[string]*websocket.Conn
+	connections  map[string]*playerConn
 	db           db.Repository
 	currentRound uint8
 	maxRounds    uint8
 	players      set.Set[string]
-    mut         *sync.Mutex
-    st           state
-    msgQ         chan []byte
-    log          logger.Logger
-}
-
-func InitGameState(gameId string, database db.Repository) *GameState {
-    gs := &GameState{
-		turnQueue:   []string{},
-		gameId:      gameId,
-		connections: make(map[string]*websocket.Conn),
-		db:          database,
-        players:     set.Set[string]{},
-        mut:         &sync.Mutex{},
-        st:          CREATED,
-        msgQ:        make(chan []byte),
-        log:         logger.New(fmt.Sprintf("GameStateLogger %s", gameId)),
-	}
-    gs.Refresh()
-    return gs
-}
-
-func (g *GameState) GetState() state { return g.st }
-
-func (g *GameState) StartGameLoop() {
-    g.mut.Lock()
-    g.st = STARTED
-    g.mut.Unlock()
-    for {
-        go g.processMessages()
-        for player := range g.players.Items() {
-            if conx, exists := g.connections[player]; exists {
-                go g.tryReadingPlayerInput(player, conx)
-            } else {
-                g.log.Error(fmt.Sprintf("No connection found for player %s", player), errors.New("Connection not found"));
-                // TODO: should remove players ?
-            }
-        }
-    }
-}
-
-func (g *GameState) broadcast(data interface{}) {}
-
-func (g *GameState) processMessages() {
-    for {
-        message := <- g.msgQ
-        jsonMap := make(map[string]interface{})
-        err := json.Unmarshal(message, &jsonMap)
-        if err != nil {
-            g.log.Error("Failed to deserialize message", err)
-            return
-        }
-        // TODO: Validate and process input
-        g.log.Info("Message processed successfully")
-    }
-}
-
-func (g *GameState) tryReadingPlayerInput(player string, c *websocket.Conn) {
+	// drawn holds the players who have had their turn in the ongoing round
+	drawn  set.Set[string]
+	drawer string
+	words  words.WordBank
+	// candidates are the words offered to the drawer, only set while they are
+	// choosing until chooseBy
+	candidates []string
+	chooseBy   time.Time
+	word       string
+	guessed    set.Set[string]
+	turnEndsAt time.Time
+	// roundEndsAt is when the next round starts, only meaningful in ROUND_END
+	roundEndsAt time.Time
+	canvas      *strokeLog
+	scoring     db.ScoringRules
+	// hintCount is how many letters of the word may be revealed over a turn,
+	// revealed holds the positions of those revealed so far
+	hintCount uint8
+	revealed  map[int]bool
+	// scores are running totals, roundScores the points earned in the ongoing round
+	// that are yet to be persisted and turnScores the points earned in the ongoing turn
+	scores             map[string]uint
+	roundScores        map[string]uint
+	turnScores         map[string]uint
+	turnDuration       time.Duration
+	wordChoiceDuration time.Duration
+	roundEndDuration   time.Duration
+	// phase counts the steps of the game loop, phaseTimers are the timers
+	// set for the ongoing one
+	phase       uint64
+	phaseTimers []clock.Timer
+	// admin is the player who gets to start the game, maxPlayers and the
+	// other settings are shown in the lobby
+	admin      string
+	maxPlayers uint8
+	// kickVotes are the running votes by target, kickVotesStarted when each
+	// player last started one
+	kickVotes        map[string]*kickVote
+	kickVotesStarted map[string]time.Time
+	voteKickDuration time.Duration
+	voteKickCooldown time.Duration
+	// absent holds the players who lost their connection, each one is removed
+	// when their timer fires unless they reconnect first
+	absent         map[string]*absence
+	reconnectGrace time.Duration
+	// held is set while a restored game waits for its players to reconnect
+	// before its game loop moves on, see holdUntilReconnected
+	held bool
+	// sessionTTL is how long the session tokens of connected players are kept
+	// valid for, they are refreshed as sessionTimer fires
+	sessionTTL   time.Duration
+	sessionTimer clock.Timer
+	st           state
+	// finishedAt is when the game was last FINISHED, lastConnected when a
+	// player was last connected to it. Both tell the reaper when to let go of it
+	finishedAt    time.Time
+	lastConnected time.Time
+	events        chan any
+	// closed is set once the game is over for good, quit is then closed as
+	// the game's goroutine returns
+	closed bool
+	quit   chan struct{}
+	// snapshots is set when the game saves a Snapshot at every turn transition
+	snapshots bool
+	// seq numbers every message the server sends to the game's players
+	seq   uint64
+	clock clock.Clock
+	log   logger.Logger
+}
+
+func InitGameState(gameId string, database db.Repository, wordBank words.WordBank, clk clock.Clock) *GameState {
+	gs := &GameState{
+		turnQueue:          []string{},
+		gameId:             gameId,
+		connections:        make(map[string]*playerConn),
+		db:                 database,
+		players:            set.Set[string]{},
+		drawn:              set.Set[string]{},
+		words:              wordBank,
+		guessed:            set.Set[string]{},
+		scoring:            DEFAULT_SCORING_RULES,
+		hintCount:          DEFAULT_HINT_COUNT,
+		revealed:           make(map[int]bool),
+		scores:             make(map[string]uint),
+		roundScores:        make(map[string]uint),
+		turnScores:         make(map[string]uint),
+		canvas:             newStrokeLog(),
+		turnDuration:       TURN_DURATION,
+		wordChoiceDuration: WORD_CHOICE_DURATION,
+		roundEndDuration:   ROUND_END_DURATION,
+		kickVotes:          make(map[string]*kickVote),
+		kickVotesStarted:   make(map[string]time.Time),
+		voteKickDuration:   VOTE_KICK_DURATION,
+		voteKickCooldown:   VOTE_KICK_COOLDOWN,
+		absent:             make(map[string]*absence),
+		reconnectGrace:     RECONNECT_GRACE_PERIOD,
+		st:                 CREATED,
+		lastConnected:      clk.Now(),
+		events:             make(chan any, EVENT_BUFFER_SIZE),
+		quit:               make(chan struct{}),
+		clock:              clk,
+		log:                logger.New(fmt.Sprintf("GameStateLogger %s", gameId)),
+	}
+	go gs.run()
+	gs.Refresh()
+	return gs
+}
+
+// GetState reports a closed game as FINISHED
+func (g *GameState) GetState() state {
+	st := FINISHED
+	_ = g.call(func() { st = g.st })
+	return st
+}
+
+// SetReconnectGrace changes how long disconnected players keep their seat,
+// players yet to reconnect get the new grace period from now on
+func (g *GameState) SetReconnectGrace(grace time.Duration) {
+	_ = g.call(func() {
+		g.reconnectGrace = grace
+		for player, away := range g.absent {
+			away.timer.Stop()
+			g.awaitReconnect(player)
+		}
+		if g.held {
+			g.holdUntilReconnected()
+		}
+	})
+}
+
+// isAbsent tells if player lost their connection and is yet to come back
+func (g *GameState) isAbsent(player string) bool {
+	_, absent := g.absent[player]
+	return absent
+}
+
+// GetDrawer returns the player drawing in the ongoing turn, empty if no turn is in progress
+func (g *GameState) GetDrawer() string {
+	var drawer string
+	_ = g.call(func() { drawer = g.drawer })
+	return drawer
+}
+
+// Start moves the game out of the lobby and into its first turn
+func (g *GameState) Start() error {
+	var err error
+	if callErr := g.call(func() { err = g.start() }); callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+// start rotates the drawer through every player once per round until
+// maxRounds have been played, after which the game is FINISHED. Each step of
+// the way is taken by the game's goroutine as players act or timers run out
+func (g *GameState) start() error {
+	if g.st != CREATED {
+		return fmt.Errorf("Game %s has already been started", g.gameId)
+	}
+	g.st = STARTED
+	if g.currentRound == 0 {
+		g.currentRound = 1
+	}
+	if g.currentRound > g.maxRounds {
+		g.finish()
+		return nil
+	}
+	g.drawn = set.Set[string]{}
+	g.log.Info(fmt.Sprintf("Starting round %d of %d", g.currentRound, g.maxRounds))
+	g.nextTurn()
+	return nil
+}
+
+// nextTurn hands the canvas to the next player yet to draw in the round, or
+// ends the round once everyone had their turn
+func (g *GameState) nextTurn() {
 	for {
-		_, msg, err := c.ReadMessage()
+		drawer, ok := g.nextDrawer()
+		if !ok {
+			g.endRound()
+			return
+		}
+		g.drawn.Insert(drawer)
+		// Players who lost their connection, or never opened one, can't draw
+		if _, connected := g.connections[drawer]; !connected {
+			g.log.Info(fmt.Sprintf("Skipping turn of player %s, they are not connected", drawer))
+			continue
+		}
+		if err := g.offerWords(drawer); err != nil {
+			g.log.Error(fmt.Sprintf("Skipping turn of player %s, no word to draw", drawer), err)
+			g.drawer = ""
+			continue
+		}
+		return
+	}
+}
+
+// endRound persists the round's scores and either finishes the game or shows
+// everyone the scoreboard, pausing before the next round begins
+func (g *GameState) endRound() {
+	round, maxRounds := g.currentRound, g.maxRounds
+	g.persistRoundScores(round)
+	if round >= maxRounds {
+		g.finish()
+		return
+	}
+	g.st = ROUND_END
+	g.log.Info(fmt.Sprintf("Round %d of %d is over", round, maxRounds))
+	g.nextPhase()
+	g.roundEndsAt = g.clock.Now().Add(g.roundEndDuration)
+	g.schedule(g.roundEndDuration, ROUND_END_TIMER)
+	g.fanOut("", parser.MSG_ROUND_END, parser.RoundEndEvent{
+		Round:       round,
+		TotalRounds: maxRounds,
+		Standings:   g.standings(),
+	})
+	g.checkpoint()
+}
+
+// startRound begins the round following the scoreboard
+func (g *GameState) startRound() {
+	g.currentRound += 1
+	g.st = STARTED
+	g.drawn = set.Set[string]{}
+	g.log.Info(fmt.Sprintf("Starting round %d of %d", g.currentRound, g.maxRounds))
+	g.nextTurn()
+}
+
+// standings ranks the players by the scores persisted so far, falling back to
+// the running totals kept in memory if the database can't be read
+func (g *GameState) standings() []parser.Standing {
+	scores, err := g.db.GetGameScores(g.gameId)
+	if err == nil {
+		return rankScores(scores)
+	}
+	g.log.Error("Failed to read scores, ranking players by in memory scores", err)
+	scores = []db.Score{}
+	for player := range g.players.Items() {
+		scores = append(scores, db.Score{Player: player, Score: g.scores[player]})
+	}
+	return rankScores(scores)
+}
+
+// nextDrawer pops the player at the head of the turnQueue and pushes them back
+// at the tail, so that every player gets to draw once per round. The round is
+// over once the head of the queue has already drawn in it
+func (g *GameState) nextDrawer() (string, bool) {
+	if len(g.turnQueue) == 0 || g.drawn.Contains(g.turnQueue[0]) {
+		return "", false
+	}
+	drawer := g.turnQueue[0]
+	g.turnQueue = append(g.turnQueue[1:], drawer)
+	return drawer, true
+}
+
+// startDrawing starts the turn of the drawer once their word is known
+func (g *GameState) startDrawing(word string, autoPicked bool) {
+	drawer, round := g.drawer, g.currentRound
+	g.candidates = nil
+	g.sendTo(drawer, parser.MSG_WORD_SELECTED, parser.WordSelectedEvent{Word: word, AutoPicked: autoPicked})
+	g.word = word
+	g.revealed = make(map[int]bool)
+	g.guessed = set.Set[string]{}
+	g.turnScores = make(map[string]uint)
+	g.canvas.clear()
+	startedAt := g.clock.Now()
+	endsAt := startedAt.Add(g.turnDuration)
+	g.turnEndsAt = endsAt
+	g.nextPhase()
+	g.schedule(g.turnDuration, TURN_TIMER)
+	for _, at := range hintSchedule(startedAt, g.turnDuration, g.hintCount) {
+		g.schedule(at.Sub(g.clock.Now()), HINT_TIMER)
+	}
+	g.log.Info(fmt.Sprintf("Player %s is drawing in round %d", drawer, round))
+	g.fanOut("", parser.MSG_TURN_START, parser.TurnStartEvent{
+		Drawer:      drawer,
+		Round:       round,
+		TotalRounds: g.maxRounds,
+		WordLength:  len([]rune(word)),
+		Hint:        maskWord(word, nil),
+		EndsAt:      endsAt,
+	})
+	g.checkpoint()
+}
+
+// endTurn closes the ongoing turn, at its deadline or as soon as every player
+// has guessed the word or the drawer is gone, and moves on to the next one
+func (g *GameState) endTurn() {
+	drawer, round, word := g.drawer, g.currentRound, g.word
+	g.nextPhase()
+	if len(word) == 0 {
+		g.log.Error(fmt.Sprintf("Skipping turn of player %s", drawer), fmt.Errorf("Player %s left before choosing a word", drawer))
+		g.candidates = nil
+		g.drawer = ""
+		g.nextTurn()
+		return
+	}
+	g.drawer = ""
+	g.word = ""
+	g.revealed = make(map[int]bool)
+	g.canvas.clear()
+	deltas := g.turnScores
+	g.turnScores = make(map[string]uint)
+	scores := make(map[string]uint)
+	for player, points := range deltas {
+		g.scores[player] += points
+		g.roundScores[player] += points
+	}
+	for player, points := range g.scores {
+		scores[player] = points
+	}
+	g.fanOut("", parser.MSG_TURN_END, parser.TurnEndEvent{Drawer: drawer, Round: round, Word: word})
+	g.fanOut("", parser.MSG_SCORE_UPDATE, parser.ScoreUpdateEvent{Deltas: deltas, Scores: scores})
+	g.nextTurn()
+}
+
+// revealHint shows one more letter of the word to everyone but the drawer
+func (g *GameState) revealHint() {
+	if !revealLetter(g.word, g.revealed) {
+		return
+	}
+	g.fanOut(g.drawer, parser.MSG_HINT, parser.HintEvent{Hint: maskWord(g.word, g.revealed)})
+}
+
+// persistRoundScores saves the points earned during round so that the
+// leaderboard survives a restart
+func (g *GameState) persistRoundScores(round uint8) {
+	roundScores := g.roundScores
+	g.roundScores = make(map[string]uint)
+	for player, points := range roundScores {
+		if points == 0 {
+			continue
+		}
+		if err := g.db.UpdatePlayerScore(g.gameId, player, points); err != nil {
+			g.log.Error(fmt.Sprintf("Failed to persist score of player %s for round %d", player, round), err)
+		}
+	}
+}
+
+// offerWords offers the drawer a few candidate words to pick from, a random
+// candidate is picked for them once the deadline passes
+func (g *GameState) offerWords(drawer string) error {
+	candidates, err := g.words.Candidates(WORD_CHOICE_COUNT)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return errors.New("Word bank returned no candidates")
+	}
+	g.drawer = drawer
+	g.candidates = candidates
+	chooseBy := g.clock.Now().Add(g.wordChoiceDuration)
+	g.chooseBy = chooseBy
+	g.nextPhase()
+	g.schedule(g.wordChoiceDuration, WORD_CHOICE_TIMER)
+	g.fanOut(drawer, parser.MSG_CHOOSING_WORD, parser.ChoosingWordEvent{Drawer: drawer, Round: g.currentRound, ChooseBy: chooseBy})
+	g.sendTo(drawer, parser.MSG_WORD_CHOICES, parser.WordChoicesEvent{Words: candidates, ChooseBy: chooseBy})
+	g.checkpoint()
+	return nil
+}
+
+// autoPickWord chooses for a drawer who didn't in time
+func (g *GameState) autoPickWord() {
+	g.log.Info(fmt.Sprintf("Player %s did not choose a word in time, picked one for them", g.drawer))
+	g.startDrawing(g.candidates[rand.Intn(len(g.candidates))], true)
+}
+
+// chooseWord starts the turn with the drawer's pick among the offered candidates
+func (g *GameState) chooseWord(player string, input parser.ChooseWordInput) error {
+	if player != g.drawer || g.candidates == nil {
+		return fmt.Errorf("Player %s is not choosing a word", player)
+	}
+	if !slices.Contains(g.candidates, input.Word) {
+		return fmt.Errorf("Word %s was not offered to player %s", input.Word, player)
+	}
+	g.startDrawing(input.Word, false)
+	return nil
+}
+
+// checkDrawing fails unless player is drawing in the ongoing turn
+func (g *GameState) checkDrawing(player string) error {
+	if player != g.drawer || len(g.word) == 0 {
+		return fmt.Errorf("Player %s is not drawing", player)
+	}
+	return nil
+}
+
+// relayStroke forwards a stroke from the drawer to every other player
+func (g *GameState) relayStroke(player string, stroke parser.Stroke) error {
+	if err := g.checkDrawing(player); err != nil {
+		return err
+	}
+	if err := g.canvas.add(stroke); err != nil {
+		return err
+	}
+	g.fanOut(player, parser.MSG_STROKE, parser.StrokeEvent{Drawer: player, Stroke: stroke})
+	return nil
+}
+
+func (g *GameState) undoStroke(player string) error {
+	if err := g.checkDrawing(player); err != nil {
+		return err
+	}
+	stroke, ok := g.canvas.undo()
+	if !ok {
+		return errors.New("Nothing to undo")
+	}
+	g.fanOut("", parser.MSG_UNDO, parser.UndoEvent{Drawer: player, StrokeId: stroke.StrokeId})
+	return nil
+}
+
+func (g *GameState) redoStroke(player string) error {
+	if err := g.checkDrawing(player); err != nil {
+		return err
+	}
+	stroke, ok := g.canvas.redo()
+	if !ok {
+		return errors.New("Nothing to redo")
+	}
+	g.fanOut("", parser.MSG_REDO, parser.StrokeEvent{Drawer: player, Stroke: stroke})
+	return nil
+}
+
+func (g *GameState) clearCanvas(player string) error {
+	if err := g.checkDrawing(player); err != nil {
+		return err
+	}
+	g.canvas.clear()
+	g.fanOut("", parser.MSG_CLEAR, parser.ClearCanvasEvent{Drawer: player})
+	return nil
+}
+
+// handleChat treats chat from guessers as a guess at the secret word. Correct
+// guesses are announced without their text, close ones are only revealed to the
+// guesser, everything else is relayed to all players as chat
+func (g *GameState) handleChat(player string, input parser.ChatInput) error {
+	text := strings.TrimSpace(input.Text)
+	if len(text) == 0 {
+		return errors.New("Empty chat message")
+	}
+	word := g.word
+	hasGuessed := player == g.drawer || g.guessed.Contains(player)
+	if len(word) == 0 {
+		g.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: player, Text: text})
+		return nil
+	}
+	if hasGuessed {
+		// The drawer and players who found the word can't give it away
+		if strings.Contains(normalizeGuess(text), normalizeGuess(word)) {
+			return fmt.Errorf("Player %s tried to reveal the word", player)
+		}
+		g.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: player, Text: text})
+		return nil
+	}
+	switch evaluateGuess(text, word) {
+	case CORRECT:
+		g.markGuessed(player)
+	case CLOSE:
+		// A near miss would give the word away to everyone else
+		g.sendTo(player, parser.MSG_CLOSE_GUESS, parser.CloseGuessEvent{Guess: text})
+	default:
+		// Neither can the word said in passing, "is it a apple?" stays private
+		if strings.Contains(normalizeGuess(text), normalizeGuess(word)) {
+			g.sendTo(player, parser.MSG_CLOSE_GUESS, parser.CloseGuessEvent{Guess: text})
+			return nil
+		}
+		g.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: player, Text: text})
+	}
+	return nil
+}
+
+// markGuessed records a correct guess and ends the turn once every guesser has found the word
+func (g *GameState) markGuessed(player string) {
+	g.guessed.Insert(player)
+	g.turnScores[player] += guessPoints(g.scoring, g.turnEndsAt.Sub(g.clock.Now()), g.turnDuration)
+	g.turnScores[g.drawer] += uint(g.scoring.DrawerPoints)
+	g.log.Info(fmt.Sprintf("Player %s guessed the word", player))
+	g.fanOut("", parser.MSG_CORRECT_GUESS, parser.CorrectGuessEvent{Player: player})
+	if g.everyoneGuessed() {
+		g.log.Info(fmt.Sprintf("Every player guessed the word drawn by %s", g.drawer))
+		g.endTurn()
+	}
+}
+
+// everyoneGuessed tells if every connected player but the drawer has guessed the word
+func (g *GameState) everyoneGuessed() bool {
+	for p := range g.connections {
+		if p != g.drawer && !g.guessed.Contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *GameState) finish() {
+	g.nextPhase()
+	g.st = FINISHED
+	g.finishedAt = g.clock.Now()
+	g.log.Info("Game finished")
+	g.fanOut("", parser.MSG_GAME_OVER, parser.GameOverEvent{RoundsPlayed: g.currentRound, Standings: g.standings()})
+	g.checkpoint()
+}
+
+// Rematch takes a finished game back to the lobby with the same players and
+// wipes their scores, the admin then starts it like a new game
+func (g *GameState) Rematch() error {
+	var err error
+	if callErr := g.call(func() { err = g.rematch() }); callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+func (g *GameState) rematch() error {
+	if g.st != FINISHED {
+		return fmt.Errorf("Game %s is not finished yet", g.gameId)
+	}
+	if err := g.db.ResetScores(g.gameId); err != nil {
+		return err
+	}
+	g.st = CREATED
+	g.currentRound = 1
+	g.scores = make(map[string]uint)
+	g.roundScores = make(map[string]uint)
+	g.turnScores = make(map[string]uint)
+	g.log.Info("Game is back in the lobby for a rematch")
+	g.fanOut("", parser.MSG_REMATCH, parser.RematchEvent{TotalRounds: g.maxRounds})
+	g.checkpoint()
+	return nil
+}
+
+// Close disconnects every player and stops the game's goroutine and timers for
+// good, the game's methods return ErrGameClosed from then on
+func (g *GameState) Close() {
+	_ = g.call(g.close)
+}
+
+func (g *GameState) close() {
+	g.nextPhase()
+	for player, away := range g.absent {
+		away.timer.Stop()
+		delete(g.absent, player)
+	}
+	for target := range g.kickVotes {
+		g.dropKickVote(target)
+	}
+	if g.sessionTimer != nil {
+		g.sessionTimer.Stop()
+	}
+	for player, pc := range g.connections {
+		pc.close()
+		delete(g.connections, player)
+	}
+	g.closed = true
+	g.log.Info("Game closed")
+}
+
+// Expired tells if the game can be let go of, either because it has been
+// FINISHED for finishedTTL or because nobody has been connected to it for
+// abandonedTTL. A game that was already closed has always expired
+func (g *GameState) Expired(now time.Time, finishedTTL, abandonedTTL time.Duration) bool {
+	var expired bool
+	if err := g.call(func() { expired = g.expired(now, finishedTTL, abandonedTTL) }); err != nil {
+		return true
+	}
+	return expired
+}
+
+func (g *GameState) expired(now time.Time, finishedTTL, abandonedTTL time.Duration) bool {
+	if g.st == FINISHED && now.Sub(g.finishedAt) >= finishedTTL {
+		return true
+	}
+	return len(g.connections) == 0 && now.Sub(g.lastConnected) >= abandonedTTL
+}
+
+// fanOut queues a message for every connected player but except
+func (g *GameState) fanOut(except string, msgType string, payload any) {
+	message, err := g.encode(msgType, payload)
+	if err != nil {
+		g.log.Error(fmt.Sprintf("Failed to serialize %s message", msgType), err)
+		return
+	}
+	for player, pc := range g.connections {
+		if player == except {
+			continue
+		}
+		g.deliver(pc, message)
+	}
+}
+
+// sendTo delivers a message to a single player, if they are connected
+func (g *GameState) sendTo(player string, msgType string, payload any) {
+	pc, exists := g.connections[player]
+	if !exists {
+		return
+	}
+	message, err := g.encode(msgType, payload)
+	if err != nil {
+		g.log.Error(fmt.Sprintf("Failed to serialize %s message", msgType), err)
+		return
+	}
+	g.deliver(pc, message)
+}
+
+// deliver queues message on the player's connection and drops players who
+// can't keep up rather than stalling the game for everyone. A dropped player
+// keeps their seat like any other player who lost their connection, the drop
+// is handled once the event being handled is over since it is usually in the
+// middle of a fanOut
+func (g *GameState) deliver(pc *playerConn, message []byte) {
+	if pc.enqueue(message) || pc.closed() {
+		return
+	}
+	g.log.Info(fmt.Sprintf("Dropping connection of player %s, they are not keeping up", pc.player))
+	pc.close()
+	go g.send(disconnectEvent{pc: pc})
+}
+
+// encode stamps the message with the game's next sequence number
+func (g *GameState) encode(msgType string, payload any) ([]byte, error) {
+	g.seq += 1
+	return parser.Encode(msgType, g.seq, payload)
+}
+
+// rejectMessage reports back to the player why their message wasn't handled
+func (g *GameState) rejectMessage(player string, inReplyTo uint64, err error) {
+	g.log.Error(fmt.Sprintf("Rejected message from player %s", player), err)
+	protocolErr, ok := err.(*parser.ProtocolError)
+	if !ok {
+		protocolErr = parser.NewProtocolError(parser.ERR_REJECTED, "%s", err.Error())
+	}
+	g.sendTo(player, parser.MSG_ERROR, parser.ErrorEvent{
+		Code:      protocolErr.Code,
+		Message:   protocolErr.Message,
+		InReplyTo: inReplyTo,
+	})
+}
+
+// handleInput decodes a message from player and acts on it
+func (g *GameState) handleInput(player string, data []byte) {
+	envelope, payload, err := parser.DecodePlayerMessage(data)
+	if err == nil {
+		switch input := payload.(type) {
+		case *parser.ChooseWordInput:
+			err = g.chooseWord(player, *input)
+		case *parser.ChatInput:
+			err = g.handleChat(player, *input)
+		case *parser.Stroke:
+			err = g.relayStroke(player, *input)
+		case *parser.UndoInput:
+			err = g.undoStroke(player)
+		case *parser.RedoInput:
+			err = g.redoStroke(player)
+		case *parser.ClearCanvasInput:
+			err = g.clearCanvas(player)
+		case *parser.VoteKickInput:
+			err = g.voteKick(player, *input)
+		}
+	}
+	if err != nil {
+		var seq uint64
+		if envelope != nil {
+			seq = envelope.Seq
+		}
+		g.rejectMessage(player, seq, err)
+		return
+	}
+	g.log.Info("Message processed successfully")
+}
+
+// tryReadingPlayerInput passes the player's messages on to the game's
+// goroutine until their connection fails
+func (g *GameState) tryReadingPlayerInput(pc *playerConn) {
+	player := pc.player
+	for {
+		_, msg, err := pc.conn.ReadMessage()
 		g.log.Info(fmt.Sprintf("Received data from player %s", player))
 		if err != nil {
 			g.log.Info(fmt.Sprintf("Player %s disconnected", player))
-            c.Close()
-			// Delete from Db
-			// gs.RemoveConnection(player.Name)
+			g.send(disconnectEvent{pc: pc})
+			return
+		}
+		if !g.send(inputEvent{player: player, data: msg}) {
 			return
 		}
-        g.msgQ <- msg
+	}
+}
+
+// connectionLost keeps the seat of a player whose connection failed for the grace period
+func (g *GameState) connectionLost(pc *playerConn) {
+	player := pc.player
+	pc.close()
+	// A player who reconnected has already replaced this connection
+	if g.connections[player] != pc {
+		return
+	}
+	delete(g.connections, player)
+	g.lastConnected = g.clock.Now()
+	g.fanOut("", parser.MSG_PLAYER_LEFT, parser.PlayerLeftEvent{Player: player, Reason: parser.LEFT_DISCONNECTED, Lobby: g.lobby()})
+	g.handOverAdmin(player)
+	g.awaitReconnect(player)
+	g.endTurnWithout(player)
+}
+
+// endTurnWithout ends the turn early once player is gone, if they were drawing
+// or the last one yet to guess the word
+func (g *GameState) endTurnWithout(player string) {
+	if player == g.drawer || (len(g.word) != 0 && g.everyoneGuessed()) {
+		g.endTurn()
 	}
 }
 
 func (g *GameState) Refresh() {
-    g.mut.Lock()
-    defer g.mut.Unlock()
+	_ = g.call(g.refresh)
+}
+
+func (g *GameState) refresh() {
 	game := g.db.GetGameById(g.gameId)
-	g.currentRound = game.CurrentRound
-	g.maxRounds = game.TotalRounds
+	if game == nil {
+		g.log.Error("Failed to refresh game state", fmt.Errorf("Game %s not found", g.gameId))
+		return
+	}
+	// Round progress is owned by the game loop once the game has started
+	if g.st == CREATED {
+		g.currentRound = game.CurrentRound
+		g.maxRounds = game.TotalRounds
+		g.maxPlayers = game.MaxPlayers
+		g.scoring = game.ScoringRules
+		g.hintCount = game.Hints
+	}
 	// Re-read all player info from DB
-    players, err := g.db.GetGamePlayers(g.gameId)
-    if err != nil {
-        g.log.Error("Failed to refresh game state", err)
-        return
-    }
-    for _, player := range players {
-        name := player.Name
-        if g.players.Contains(name) {continue}
-        g.players.Insert(name)
-        g.turnQueue = append(g.turnQueue, name)
-    }
-    g.log.Info("Refreshed GameState successfully")
+	players, err := g.db.GetGamePlayers(g.gameId)
+	if err != nil {
+		g.log.Error("Failed to refresh game state", err)
+		return
+	}
+	joined := []string{}
+	admin := g.admin
+	for _, player := range players {
+		name := player.Name
+		if player.IsAdmin {
+			admin = name
+		}
+		if g.players.Contains(name) {
+			continue
+		}
+		g.players.Insert(name)
+		g.turnQueue = append(g.turnQueue, name)
+		joined = append(joined, name)
+	}
+	adminChanged := len(g.admin) != 0 && admin != g.admin
+	g.admin = admin
+	for _, name := range joined {
+		g.fanOut(name, parser.MSG_PLAYER_JOINED, parser.PlayerJoinedEvent{Player: name, Lobby: g.lobby()})
+	}
+	if adminChanged {
+		g.fanOut("", parser.MSG_ADMIN_CHANGED, parser.AdminChangedEvent{Admin: admin, Lobby: g.lobby()})
+	}
+	g.log.Info("Refreshed GameState successfully")
+}
+
+// lobby describes the waiting room
+func (g *GameState) lobby() parser.Lobby {
+	players := g.players.Slice()
+	slices.Sort(players)
+	lobby := parser.Lobby{
+		Players: make([]parser.LobbyPlayer, len(players)),
+		Settings: parser.GameSettings{
+			MaxPlayers:  g.maxPlayers,
+			TotalRounds: g.maxRounds,
+			Hints:       g.hintCount,
+			Scoring: parser.ScoringRules{
+				GuessMaxPoints: g.scoring.GuessMaxPoints,
+				GuessMinPoints: g.scoring.GuessMinPoints,
+				DrawerPoints:   g.scoring.DrawerPoints,
+			},
+		},
+	}
+	for i, player := range players {
+		_, connected := g.connections[player]
+		lobby.Players[i] = parser.LobbyPlayer{Name: player, IsAdmin: player == g.admin, Connected: connected}
+	}
+	return lobby
 }
 
+// AddConnection attaches conn as the player's connection, replacing any
+// previous one, and starts reading their messages. conn is closed right away
+// if the game has been closed
 func (g *GameState) AddConnection(player string, conn *websocket.Conn) {
-    g.players.Insert(player)
-	g.connections[player] = conn
-    g.log.Info(fmt.Sprintf("Connection for player %s added successfully", player))
+	done := make(chan struct{})
+	if g.send(connectEvent{player: player, conn: conn, done: done}) {
+		select {
+		case <-done:
+			return
+		case <-g.quit:
+		}
+	}
+	select {
+	case <-done:
+	default:
+		conn.Close()
+	}
 }
 
-func (g *GameState) RemoveConnection(player string) {
-    g.players.Remove(player)
-    g.db.DeletePlayer(g.gameId, player)
-	delete(g.connections, player)
-    g.log.Info(fmt.Sprintf("Connection for player %s removed successfully", player))
+func (g *GameState) addConnection(player string, conn *websocket.Conn) {
+	g.players.Insert(player)
+	if previous, exists := g.connections[player]; exists {
+		previous.close()
+	}
+	if away, wasAbsent := g.absent[player]; wasAbsent {
+		away.timer.Stop()
+		delete(g.absent, player)
+		g.log.Info(fmt.Sprintf("Player %s is back", player))
+	}
+	pc := newPlayerConn(player, conn, g.log, g.clock)
+	if snapshot, err := g.encode(parser.MSG_CANVAS_SNAPSHOT, g.canvasSnapshot(player)); err == nil {
+		pc.enqueue(snapshot)
+	} else {
+		g.log.Error(fmt.Sprintf("Failed to serialize canvas snapshot for player %s", player), err)
+	}
+	g.connections[player] = pc
+	g.lastConnected = g.clock.Now()
+	go g.tryReadingPlayerInput(pc)
+	g.fanOut("", parser.MSG_LOBBY, g.lobby())
+	g.log.Info(fmt.Sprintf("Connection for player %s added successfully", player))
+	// The admin may have left for good with nobody there to take over
+	if len(g.admin) != 0 && !g.players.Contains(g.admin) {
+		g.handOverAdmin(g.admin)
+	}
+	g.resumeOnceReconnected()
+}
+
+// canvasSnapshot captures the ongoing turn as seen by player
+func (g *GameState) canvasSnapshot(player string) parser.CanvasSnapshotEvent {
+	snapshot := parser.CanvasSnapshotEvent{
+		Round:       g.currentRound,
+		TotalRounds: g.maxRounds,
+		Drawer:      g.drawer,
+		Strokes:     g.canvas.snapshot(),
+	}
+	if len(g.word) != 0 {
+		endsAt := g.turnEndsAt
+		snapshot.EndsAt = &endsAt
+		snapshot.WordLength = len([]rune(g.word))
+		if player == g.drawer {
+			snapshot.Word = g.word
+		} else {
+			snapshot.Hint = maskWord(g.word, g.revealed)
+		}
+	}
+	return snapshot
+}
+
+// HasPlayer tells if player is part of the game, connected or not
+func (g *GameState) HasPlayer(player string) bool {
+	var found bool
+	_ = g.call(func() { found = g.players.Contains(player) })
+	return found
+}
+
+// RemoveConnection takes player out of the game for good, reason is passed on
+// to the remaining players. The turn ends early if player was drawing or was
+// the last one yet to guess the word
+func (g *GameState) RemoveConnection(player, reason string) error {
+	result := make(chan error, 1)
+	if !g.send(removeEvent{player: player, reason: reason, result: result}) {
+		return ErrGameClosed
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-g.quit:
+		select {
+		case err := <-result:
+			return err
+		default:
+			return ErrGameClosed
+		}
+	}
+}
+
+// awaitReconnect keeps the seat of a player who lost their connection for the grace period
+func (g *GameState) awaitReconnect(player string) {
+	away := &absence{}
+	away.timer = g.after(g.reconnectGrace, timerEvent{kind: RECONNECT_TIMER, player: player, absence: away})
+	g.absent[player] = away
+}
+
+func (g *GameState) reconnectTimedOut(player string, away *absence) {
+	// The player may have come back, or left for good, as the timer fired
+	if g.absent[player] != away {
+		return
+	}
+	g.log.Info(fmt.Sprintf("Player %s did not reconnect in time", player))
+	if err := g.removePlayer(player, parser.LEFT_TIMED_OUT); err != nil {
+		g.log.Error(fmt.Sprintf("Failed to remove player %s", player), err)
+	}
+}
+
+func (g *GameState) removePlayer(player, reason string) error {
+	if err := g.db.DeletePlayer(g.gameId, player); err != nil {
+		return err
+	}
+	if away, wasAbsent := g.absent[player]; wasAbsent {
+		away.timer.Stop()
+		delete(g.absent, player)
+	}
+	g.players.Remove(player)
+	g.dropKickVote(player)
+	g.turnQueue = slices.DeleteFunc(g.turnQueue, func(p string) bool { return p == player })
+	if pc, exists := g.connections[player]; exists {
+		pc.close()
+		delete(g.connections, player)
+		g.lastConnected = g.clock.Now()
+	}
+	g.fanOut("", parser.MSG_PLAYER_LEFT, parser.PlayerLeftEvent{Player: player, Reason: reason, Lobby: g.lobby()})
+	g.handOverAdmin(player)
+	g.log.Info(fmt.Sprintf("Connection for player %s removed successfully", player))
+	g.endTurnWithout(player)
+	g.resumeOnceReconnected()
+	return nil
+}
+
+// handOverAdmin promotes the remaining player who has been connected the longest
+// once the admin is gone. An admin who left for good while nobody else is
+// connected is succeeded by the player who joined the game first
+func (g *GameState) handOverAdmin(gone string) {
+	if gone != g.admin {
+		return
+	}
+	var successor *playerConn
+	for player, pc := range g.connections {
+		if player == gone {
+			continue
+		}
+		if successor == nil || pc.connectedAt.Before(successor.connectedAt) ||
+			(pc.connectedAt.Equal(successor.connectedAt) && player < successor.player) {
+			successor = pc
+		}
+	}
+	next := ""
+	if successor != nil {
+		next = successor.player
+	} else if !g.players.Contains(gone) {
+		next = g.longestSeated()
+	}
+	if len(next) == 0 {
+		g.log.Info(fmt.Sprintf("Admin %s left, nobody is there to take over", gone))
+		return
+	}
+	if err := g.db.SetAdmin(g.gameId, next); err != nil {
+		g.log.Error(fmt.Sprintf("Failed to hand admin over to player %s", next), err)
+		return
+	}
+	g.admin = next
+	g.fanOut("", parser.MSG_ADMIN_CHANGED, parser.AdminChangedEvent{Admin: g.admin, Lobby: g.lobby()})
+}
+
+// longestSeated is the player still in the game who joined it first
+func (g *GameState) longestSeated() string {
+	players, err := g.db.GetGamePlayers(g.gameId)
+	if err != nil {
+		g.log.Error("Failed to fetch players of the game", err)
+		return ""
+	}
+	for _, player := range players {
+		if g.players.Contains(player.Name) {
+			return player.Name
+		}
+	}
+	return ""
 }
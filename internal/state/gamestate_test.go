@@ -0,0 +1,795 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	dbMock "github.com/anchal00/doodle/internal/db/mocks"
+	"github.com/anchal00/doodle/internal/parser"
+	"github.com/anchal00/doodle/internal/words"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// connectPlayer attaches a real websocket connection for player to the game and
+// returns the client end of it
+func connectPlayer(t *testing.T, gs *GameState, player string) *websocket.Conn {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err, "Failed to upgrade test connection")
+		serverConns <- conn
+	}))
+	t.Cleanup(server.Close)
+	client, _, err := websocket.DefaultDialer.Dial(strings.ReplaceAll(server.URL, "http:", "ws:"), nil)
+	require.Nil(t, err, "Failed to dial test connection")
+	t.Cleanup(func() { client.Close() })
+	gs.AddConnection(player, <-serverConns)
+	return client
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) *parser.Envelope {
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.Nil(t, err, "Failed to read game event")
+	event, err := parser.Decode(data)
+	require.Nil(t, err, "Failed to decode game event")
+	return event
+}
+
+// expectEvent reads events off conn, skipping those of other types, until one of eventType arrives
+func expectEvent(t *testing.T, conn *websocket.Conn, eventType string) *parser.Envelope {
+	for {
+		event := readEvent(t, conn)
+		if event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func sendInput(t *testing.T, conn *websocket.Conn, inputType string, payload any) {
+	data, err := parser.Encode(inputType, 0, payload)
+	require.Nil(t, err)
+	require.Nil(t, conn.WriteMessage(websocket.TextMessage, data), "Failed to send player input")
+}
+
+func isAbsent(gs *GameState, player string) bool {
+	var absent bool
+	gs.call(func() { absent = gs.isAbsent(player) })
+	return absent
+}
+
+// newTestRepo holds game "xxxxxx" with players, none of whom scored yet
+func newTestRepo(t *testing.T, totalRounds uint8, players ...string) *dbMock.Repository {
+	repo := dbMock.NewRepository(t)
+	dbPlayers := []db.Player{}
+	dbScores := []db.Score{}
+	for _, name := range players {
+		dbPlayers = append(dbPlayers, db.Player{Name: name, GameId: "xxxxxx"})
+		dbScores = append(dbScores, db.Score{Player: name})
+	}
+	repo.On("GetGameById", "xxxxxx").Return(&db.Game{
+		GameId:       "xxxxxx",
+		CurrentRound: 1,
+		TotalRounds:  totalRounds,
+		ScoringRules: DEFAULT_SCORING_RULES,
+	})
+	repo.On("GetGamePlayers", "xxxxxx").Return(dbPlayers, nil)
+	repo.On("GetGameScores", "xxxxxx").Return(dbScores, nil).Maybe()
+	return repo
+}
+
+func newTestGameState(t *testing.T, totalRounds uint8, players ...string) *GameState {
+	repo := newTestRepo(t, totalRounds, players...)
+	gs := InitGameState("xxxxxx", repo, words.NewStaticWordBank([]string{"apple", "banana", "cherry"}), clock.New())
+	gs.turnDuration = 20 * time.Millisecond
+	gs.wordChoiceDuration = 20 * time.Millisecond
+	gs.roundEndDuration = 20 * time.Millisecond
+	// Players who disconnect as a test ends must not be timed out once it's over
+	gs.reconnectGrace = time.Hour
+	return gs
+}
+
+func TestGameLoopRotatesDrawers(t *testing.T) {
+	gs := newTestGameState(t, 2, "alice", "bob")
+	alice := connectPlayer(t, gs, "alice")
+	connectPlayer(t, gs, "bob")
+
+	require.Nil(t, gs.Start())
+	assert.Equal(t, STARTED, gs.GetState())
+	assert.NotNil(t, gs.Start(), "Starting an already started game should fail")
+
+	expectedTurns := []parser.TurnStartEvent{
+		{Drawer: "alice", Round: 1}, {Drawer: "bob", Round: 1},
+		{Drawer: "alice", Round: 2}, {Drawer: "bob", Round: 2},
+	}
+	for _, expected := range expectedTurns {
+		event := expectEvent(t, alice, parser.MSG_TURN_START)
+		turn := parser.TurnStartEvent{}
+		require.Nil(t, json.Unmarshal(event.Payload, &turn))
+		assert.Equal(t, expected.Drawer, turn.Drawer)
+		assert.Equal(t, expected.Round, turn.Round)
+		assert.Equal(t, uint8(2), turn.TotalRounds)
+		expectEvent(t, alice, parser.MSG_TURN_END)
+	}
+	expectEvent(t, alice, parser.MSG_GAME_OVER)
+	assert.Equal(t, FINISHED, gs.GetState())
+}
+
+func TestPlayersWithoutConnectionSkipped(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	// alice joined but never opened her websocket
+	bob := connectPlayer(t, gs, "bob")
+	require.Nil(t, gs.Start())
+
+	turn := parser.TurnStartEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_TURN_START).Payload, &turn))
+	assert.Equal(t, "bob", turn.Drawer)
+	expectEvent(t, bob, parser.MSG_TURN_END)
+	expectEvent(t, bob, parser.MSG_GAME_OVER)
+}
+
+func TestDrawerChoosesWord(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	require.Nil(t, gs.Start())
+
+	choosing := parser.ChoosingWordEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_CHOOSING_WORD).Payload, &choosing))
+	assert.Equal(t, "alice", choosing.Drawer)
+	// Only the drawer gets to see the candidates
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	assert.Len(t, choices.Words, WORD_CHOICE_COUNT)
+
+	// A word that wasn't offered is rejected
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: "durian"})
+	rejection := parser.ErrorEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ERROR).Payload, &rejection))
+	assert.Equal(t, parser.ERR_REJECTED, rejection.Code)
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: choices.Words[1]})
+	selected := parser.WordSelectedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_SELECTED).Payload, &selected))
+	assert.Equal(t, choices.Words[1], selected.Word)
+	assert.False(t, selected.AutoPicked)
+
+	turn := parser.TurnStartEvent{}
+	event := expectEvent(t, bob, parser.MSG_TURN_START)
+	require.Nil(t, json.Unmarshal(event.Payload, &turn))
+	assert.Equal(t, len(choices.Words[1]), turn.WordLength)
+	assert.NotContains(t, string(event.Payload), choices.Words[1], "Guessers must not receive the word")
+}
+
+func TestWordAutoPickedOnTimeout(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice")
+	alice := connectPlayer(t, gs, "alice")
+	require.Nil(t, gs.Start())
+
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	selected := parser.WordSelectedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_SELECTED).Payload, &selected))
+	assert.True(t, selected.AutoPicked)
+	assert.Contains(t, choices.Words, selected.Word)
+}
+
+func TestGuessing(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.turnDuration = 5 * time.Second
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	carol := connectPlayer(t, gs, "carol")
+	// bob and carol still have their turns to play after the test is over
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", "xxxxxx", mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	word := choices.Words[0]
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: word})
+	expectEvent(t, bob, parser.MSG_TURN_START)
+	expectEvent(t, carol, parser.MSG_TURN_START)
+
+	// A near miss is only flagged to the guesser
+	sendInput(t, bob, parser.MSG_CHAT, parser.ChatInput{Text: word[1:]})
+	closeGuess := parser.CloseGuessEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_CLOSE_GUESS).Payload, &closeGuess))
+	assert.Equal(t, word[1:], closeGuess.Guess)
+	// So is a sentence with the word in it
+	sendInput(t, bob, parser.MSG_CHAT, parser.ChatInput{Text: "is it a " + word + "?"})
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_CLOSE_GUESS).Payload, &closeGuess))
+	assert.Equal(t, "is it a "+word+"?", closeGuess.Guess)
+
+	// carol never hears of bob's near misses, the next thing she learns is that he found the word
+	sendInput(t, bob, parser.MSG_CHAT, parser.ChatInput{Text: strings.ToUpper(word) + "!"})
+	event := readEvent(t, carol)
+	require.Equal(t, parser.MSG_CORRECT_GUESS, event.Type)
+	assert.NotContains(t, strings.ToLower(string(event.Payload)), word, "Correct guesses must not be echoed")
+
+	// Once bob found the word, he can't spell it out for carol
+	sendInput(t, bob, parser.MSG_CHAT, parser.ChatInput{Text: "it is " + word})
+	sendInput(t, carol, parser.MSG_CHAT, parser.ChatInput{Text: word})
+	expectEvent(t, alice, parser.MSG_CORRECT_GUESS)
+	correct := parser.CorrectGuessEvent{}
+	event = readEvent(t, alice)
+	require.Equal(t, parser.MSG_CORRECT_GUESS, event.Type)
+	require.Nil(t, json.Unmarshal(event.Payload, &correct))
+	assert.Equal(t, "carol", correct.Player)
+
+	// Everyone guessed, so the turn ends well before its deadline
+	turnEnd := parser.TurnEndEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_END).Payload, &turnEnd))
+	assert.Equal(t, word, turnEnd.Word)
+
+	// Earlier guesses are worth more, the drawer is paid for every correct guesser
+	update := parser.ScoreUpdateEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_SCORE_UPDATE).Payload, &update))
+	assert.GreaterOrEqual(t, update.Deltas["bob"], update.Deltas["carol"])
+	assert.GreaterOrEqual(t, update.Deltas["carol"], uint(DEFAULT_SCORING_RULES.GuessMinPoints))
+	assert.Equal(t, 2*uint(DEFAULT_SCORING_RULES.DrawerPoints), update.Deltas["alice"])
+	assert.Equal(t, update.Deltas, update.Scores)
+}
+
+func TestScoresPersistedEachRound(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.turnDuration = 5 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	repo := gs.db.(*dbMock.Repository)
+	repo.On("UpdatePlayerScore", "xxxxxx", mock.Anything, mock.Anything).Return(nil)
+	require.Nil(t, gs.Start())
+
+	totals := map[string]uint{}
+	for _, turn := range []struct{ drawer, guesser *websocket.Conn }{{alice, bob}, {bob, alice}} {
+		selected := parser.WordSelectedEvent{}
+		require.Nil(t, json.Unmarshal(expectEvent(t, turn.drawer, parser.MSG_WORD_SELECTED).Payload, &selected))
+		expectEvent(t, turn.guesser, parser.MSG_TURN_START)
+		sendInput(t, turn.guesser, parser.MSG_CHAT, parser.ChatInput{Text: selected.Word})
+		update := parser.ScoreUpdateEvent{}
+		require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_SCORE_UPDATE).Payload, &update))
+		totals = update.Scores
+	}
+	expectEvent(t, alice, parser.MSG_GAME_OVER)
+	repo.AssertCalled(t, "UpdatePlayerScore", "xxxxxx", "alice", totals["alice"])
+	repo.AssertCalled(t, "UpdatePlayerScore", "xxxxxx", "bob", totals["bob"])
+	repo.AssertNumberOfCalls(t, "UpdatePlayerScore", 2)
+}
+
+func TestMalformedInputIsRejected(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice")
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	require.Nil(t, gs.Start())
+	expectEvent(t, alice, parser.MSG_WORD_CHOICES)
+
+	require.Nil(t, alice.WriteMessage(websocket.TextMessage, []byte(`{"version":1,"type":"dance","seq":42,"payload":{}}`)))
+	rejection := parser.ErrorEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ERROR).Payload, &rejection))
+	assert.Equal(t, parser.ERR_UNKNOWN_TYPE, rejection.Code)
+	assert.Equal(t, uint64(42), rejection.InReplyTo)
+
+	require.Nil(t, alice.WriteMessage(websocket.TextMessage, []byte(`{{`)))
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ERROR).Payload, &rejection))
+	assert.Equal(t, parser.ERR_MALFORMED, rejection.Code)
+}
+
+func TestOversizedMessageDropsConnection(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice")
+	alice := connectPlayer(t, gs, "alice")
+
+	require.Nil(t, alice.WriteMessage(websocket.TextMessage, make([]byte, parser.MAX_MESSAGE_SIZE+1)))
+	require.Nil(t, alice.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		if _, _, err := alice.ReadMessage(); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+			break
+		}
+	}
+	assert.Eventually(t, func() bool { return isAbsent(gs, "alice") }, time.Second, 10*time.Millisecond)
+}
+
+func TestSlowConnectionIsDropped(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	alice := connectPlayer(t, gs, "alice")
+	gs.call(func() {
+		// bob's write pump never runs, so his buffer fills up after a single message
+		gs.connections["bob"] = &playerConn{
+			player:    "bob",
+			send:      make(chan []byte, 1),
+			done:      make(chan struct{}),
+			closeOnce: &sync.Once{},
+			log:       gs.log,
+		}
+		gs.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: "alice", Text: "one"})
+		gs.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: "alice", Text: "two"})
+		gs.fanOut("alice", parser.MSG_CHAT, parser.ChatEvent{Player: "bob", Text: "three"})
+		gs.fanOut("", parser.MSG_CHAT, parser.ChatEvent{Player: "alice", Text: "four"})
+	})
+
+	for _, expected := range []string{"one", "two", "four"} {
+		chat := parser.ChatEvent{}
+		require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_CHAT).Payload, &chat))
+		assert.Equal(t, expected, chat.Text)
+	}
+	// bob keeps his seat like any player who lost their connection
+	left := parser.PlayerLeftEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.PlayerLeftEvent{Player: "bob", Reason: parser.LEFT_DISCONNECTED, Lobby: left.Lobby}, left)
+	assert.True(t, isAbsent(gs, "bob"))
+	gs.call(func() {
+		assert.NotContains(t, gs.connections, "bob")
+		assert.Contains(t, gs.connections, "alice")
+	})
+}
+
+func testStroke(strokeId uint32) parser.Stroke {
+	return parser.Stroke{
+		StrokeId:  strokeId,
+		Tool:      parser.TOOL_PEN,
+		Color:     "#000000",
+		BrushSize: 3,
+		Canvas:    parser.Canvas{Width: 800, Height: 600},
+		Points:    []parser.Point{{X: 10, Y: 20}, {X: 300, Y: 400}},
+	}
+}
+
+func TestStrokesRelayedFromDrawerOnly(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.turnDuration = 5 * time.Second
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	carol := connectPlayer(t, gs, "carol")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", "xxxxxx", mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	// Drawing before the word has been chosen is not allowed
+	sendInput(t, alice, parser.MSG_STROKE, testStroke(1))
+	expectEvent(t, alice, parser.MSG_ERROR)
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: choices.Words[0]})
+	expectEvent(t, bob, parser.MSG_TURN_START)
+	expectEvent(t, carol, parser.MSG_TURN_START)
+
+	sendInput(t, bob, parser.MSG_STROKE, testStroke(2))
+	rejection := parser.ErrorEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_ERROR).Payload, &rejection))
+	assert.Equal(t, parser.ERR_REJECTED, rejection.Code)
+
+	sendInput(t, alice, parser.MSG_STROKE, testStroke(3))
+	for _, guesser := range []*websocket.Conn{bob, carol} {
+		stroke := parser.StrokeEvent{}
+		event := readEvent(t, guesser)
+		require.Equal(t, parser.MSG_STROKE, event.Type)
+		require.Nil(t, json.Unmarshal(event.Payload, &stroke))
+		assert.Equal(t, "alice", stroke.Drawer)
+		assert.Equal(t, testStroke(3), stroke.Stroke)
+	}
+	// The drawer doesn't get their own strokes echoed back
+	sendInput(t, alice, parser.MSG_CHAT, parser.ChatInput{Text: "hint: it's a fruit"})
+	for event := readEvent(t, alice); event.Type != parser.MSG_CHAT; event = readEvent(t, alice) {
+		assert.NotEqual(t, parser.MSG_STROKE, event.Type)
+	}
+}
+
+func TestLateJoinerReceivesCanvasSnapshot(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.turnDuration = 5 * time.Second
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", "xxxxxx", mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	word := choices.Words[0]
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: word})
+	expectEvent(t, bob, parser.MSG_TURN_START)
+	// Two batches of the same stroke, then a fill
+	first, second, fill := testStroke(1), testStroke(1), testStroke(2)
+	second.Points = []parser.Point{{X: 500, Y: 500}}
+	fill.Tool, fill.Points = parser.TOOL_FILL, []parser.Point{{X: 1, Y: 1}}
+	for _, stroke := range []parser.Stroke{first, second, fill} {
+		sendInput(t, alice, parser.MSG_STROKE, stroke)
+		expectEvent(t, bob, parser.MSG_STROKE)
+	}
+
+	carol := connectPlayer(t, gs, "carol")
+	event := readEvent(t, carol)
+	require.Equal(t, parser.MSG_CANVAS_SNAPSHOT, event.Type, "The snapshot must be the first frame")
+	snapshot := parser.CanvasSnapshotEvent{}
+	require.Nil(t, json.Unmarshal(event.Payload, &snapshot))
+	assert.Equal(t, "alice", snapshot.Drawer)
+	assert.Equal(t, len(word), snapshot.WordLength)
+	assert.Empty(t, snapshot.Word, "Guessers must not receive the word")
+	assert.NotNil(t, snapshot.EndsAt)
+	require.Len(t, snapshot.Strokes, 2)
+	assert.Equal(t, append(first.Points, second.Points...), snapshot.Strokes[0].Points)
+	assert.Equal(t, fill, snapshot.Strokes[1])
+
+	// The drawer reconnecting gets their word back
+	alice = connectPlayer(t, gs, "alice")
+	require.Nil(t, json.Unmarshal(readEvent(t, alice).Payload, &snapshot))
+	assert.Equal(t, word, snapshot.Word)
+
+	// Late joiners take part in the game like everyone else
+	sendInput(t, carol, parser.MSG_CHAT, parser.ChatInput{Text: word})
+	correct := parser.CorrectGuessEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_CORRECT_GUESS).Payload, &correct))
+	assert.Equal(t, "carol", correct.Player)
+}
+
+func TestDrawerUndoRedoAndClear(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.turnDuration = 5 * time.Second
+	gs.wordChoiceDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", "xxxxxx", mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: choices.Words[0]})
+	expectEvent(t, bob, parser.MSG_TURN_START)
+	for strokeId := uint32(1); strokeId <= 2; strokeId += 1 {
+		sendInput(t, alice, parser.MSG_STROKE, testStroke(strokeId))
+		expectEvent(t, bob, parser.MSG_STROKE)
+	}
+
+	// Guessers can't touch the canvas
+	sendInput(t, bob, parser.MSG_UNDO, parser.UndoInput{})
+	expectEvent(t, bob, parser.MSG_ERROR)
+
+	sendInput(t, alice, parser.MSG_UNDO, parser.UndoInput{})
+	undo := parser.UndoEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_UNDO).Payload, &undo))
+	assert.Equal(t, uint32(2), undo.StrokeId)
+	expectEvent(t, alice, parser.MSG_UNDO)
+
+	sendInput(t, alice, parser.MSG_REDO, parser.RedoInput{})
+	redo := parser.StrokeEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_REDO).Payload, &redo))
+	assert.Equal(t, testStroke(2), redo.Stroke)
+	sendInput(t, alice, parser.MSG_REDO, parser.RedoInput{})
+	expectEvent(t, alice, parser.MSG_ERROR)
+
+	sendInput(t, alice, parser.MSG_CLEAR, parser.ClearCanvasInput{})
+	expectEvent(t, bob, parser.MSG_CLEAR)
+
+	// Everyone converges on the same, now empty, canvas
+	carol := connectPlayer(t, gs, "carol")
+	snapshot := parser.CanvasSnapshotEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, carol, parser.MSG_CANVAS_SNAPSHOT).Payload, &snapshot))
+	assert.Empty(t, snapshot.Strokes)
+}
+
+func TestHintsRevealedToGuessers(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.hintCount = 2
+	gs.turnDuration = 300 * time.Millisecond
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	selected := parser.WordSelectedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_SELECTED).Payload, &selected))
+	turn := parser.TurnStartEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_TURN_START).Payload, &turn))
+	assert.Equal(t, maskWord(selected.Word, nil), turn.Hint)
+
+	previous := turn.Hint
+	for i := 0; i < 2; i += 1 {
+		hint := parser.HintEvent{}
+		require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_HINT).Payload, &hint))
+		assert.Equal(t, len(selected.Word), len(hint.Hint))
+		assert.Equal(t, strings.Count(previous, "_")-1, strings.Count(hint.Hint, "_"), "Every hint reveals one more letter")
+		previous = hint.Hint
+	}
+	// The drawer already knows the word and gets no hints
+	for {
+		event := readEvent(t, alice)
+		assert.NotEqual(t, parser.MSG_HINT, event.Type)
+		if event.Type == parser.MSG_TURN_END {
+			break
+		}
+	}
+}
+
+func TestRoundEndGameOverAndRematch(t *testing.T) {
+	gs := newTestGameState(t, 2, "alice", "bob")
+	alice := connectPlayer(t, gs, "alice")
+	connectPlayer(t, gs, "bob")
+	repo := gs.db.(*dbMock.Repository)
+	repo.On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	repo.On("ResetScores", "xxxxxx").Return(nil).Once()
+	require.NotNil(t, gs.Rematch(), "A game that hasn't finished can't be rematched")
+	require.Nil(t, gs.Start())
+
+	roundEnd := parser.RoundEndEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ROUND_END).Payload, &roundEnd))
+	assert.Equal(t, uint8(1), roundEnd.Round)
+	assert.Equal(t, uint8(2), roundEnd.TotalRounds)
+	gameOver := parser.GameOverEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_GAME_OVER).Payload, &gameOver))
+	assert.Equal(t, uint8(2), gameOver.RoundsPlayed)
+	// Nobody scored, so both players share the first place
+	assert.Equal(t, []parser.Standing{{Rank: 1, Player: "alice"}, {Rank: 1, Player: "bob"}}, gameOver.Standings)
+	assert.Equal(t, FINISHED, gs.GetState())
+
+	gs.call(func() { gs.scores["alice"] = 40 })
+	require.Nil(t, gs.Rematch())
+	rematch := parser.RematchEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_REMATCH).Payload, &rematch))
+	assert.Equal(t, uint8(2), rematch.TotalRounds)
+	assert.Equal(t, CREATED, gs.GetState())
+	gs.call(func() {
+		assert.Empty(t, gs.scores)
+		assert.Equal(t, uint8(1), gs.currentRound)
+	})
+
+	// The same players play again without reconnecting
+	require.Nil(t, gs.Start())
+	turn := parser.TurnStartEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_START).Payload, &turn))
+	assert.Equal(t, uint8(1), turn.Round)
+}
+
+func TestLobbyEvents(t *testing.T) {
+	repo := dbMock.NewRepository(t)
+	repo.On("GetGameById", "xxxxxx").Return(&db.Game{GameId: "xxxxxx", CurrentRound: 1, MaxPlayers: 4, TotalRounds: 2, Hints: 1, ScoringRules: DEFAULT_SCORING_RULES})
+	alicePlayer := db.Player{Name: "alice", GameId: "xxxxxx", IsAdmin: true}
+	bobPlayer := db.Player{Name: "bob", GameId: "xxxxxx"}
+	repo.On("GetGamePlayers", "xxxxxx").Return([]db.Player{alicePlayer}, nil).Once()
+	gs := InitGameState("xxxxxx", repo, words.NewStaticWordBank([]string{"apple"}), clock.New())
+	gs.reconnectGrace = time.Hour
+	alice := connectPlayer(t, gs, "alice")
+
+	lobby := parser.Lobby{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_LOBBY).Payload, &lobby))
+	assert.Equal(t, []parser.LobbyPlayer{{Name: "alice", IsAdmin: true, Connected: true}}, lobby.Players)
+	assert.Equal(t, parser.GameSettings{MaxPlayers: 4, TotalRounds: 2, Hints: 1, Scoring: parser.ScoringRules{GuessMaxPoints: 100, GuessMinPoints: 20, DrawerPoints: 25}}, lobby.Settings)
+
+	// Joining through the REST api refreshes the game state
+	repo.On("GetGamePlayers", "xxxxxx").Return([]db.Player{alicePlayer, bobPlayer}, nil).Once()
+	gs.Refresh()
+	joined := parser.PlayerJoinedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_JOINED).Payload, &joined))
+	assert.Equal(t, "bob", joined.Player)
+	assert.Equal(t, []parser.LobbyPlayer{{Name: "alice", IsAdmin: true, Connected: true}, {Name: "bob"}}, joined.Lobby.Players)
+
+	bob := connectPlayer(t, gs, "bob")
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_LOBBY).Payload, &lobby))
+	assert.True(t, lobby.Players[1].Connected)
+
+	alicePlayer.IsAdmin, bobPlayer.IsAdmin = false, true
+	repo.On("GetGamePlayers", "xxxxxx").Return([]db.Player{alicePlayer, bobPlayer}, nil).Once()
+	gs.Refresh()
+	adminChanged := parser.AdminChangedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ADMIN_CHANGED).Payload, &adminChanged))
+	assert.Equal(t, "bob", adminChanged.Admin)
+
+	repo.On("SetAdmin", "xxxxxx", "alice").Return(nil).Once()
+	bob.Close()
+	left := parser.PlayerLeftEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.PlayerLeftEvent{Player: "bob", Reason: parser.LEFT_DISCONNECTED, Lobby: left.Lobby}, left)
+	assert.False(t, left.Lobby.Players[1].Connected)
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_ADMIN_CHANGED).Payload, &adminChanged))
+	assert.Equal(t, "alice", adminChanged.Admin)
+
+	repo.On("DeletePlayer", "xxxxxx", "bob").Return(nil).Once()
+	require.Nil(t, gs.RemoveConnection("bob", parser.LEFT_KICKED))
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.LEFT_KICKED, left.Reason)
+	assert.Len(t, left.Lobby.Players, 1)
+}
+
+func TestAdminHandedOverToLongestConnected(t *testing.T) {
+	repo := dbMock.NewRepository(t)
+	repo.On("GetGameById", "xxxxxx").Return(&db.Game{GameId: "xxxxxx", CurrentRound: 1, TotalRounds: 1})
+	repo.On("GetGamePlayers", "xxxxxx").Return([]db.Player{
+		{Name: "alice", GameId: "xxxxxx", IsAdmin: true},
+		{Name: "bob", GameId: "xxxxxx"},
+		{Name: "carol", GameId: "xxxxxx"},
+	}, nil)
+	gs := InitGameState("xxxxxx", repo, words.NewStaticWordBank([]string{"apple"}), clock.New())
+	gs.reconnectGrace = time.Hour
+	connectPlayer(t, gs, "alice")
+	// carol connects before bob, so she has been around the longest
+	carol := connectPlayer(t, gs, "carol")
+	bob := connectPlayer(t, gs, "bob")
+
+	repo.On("DeletePlayer", "xxxxxx", "alice").Return(nil).Once()
+	repo.On("SetAdmin", "xxxxxx", "carol").Return(nil).Once()
+	require.Nil(t, gs.RemoveConnection("alice", parser.LEFT_KICKED))
+	adminChanged := parser.AdminChangedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_ADMIN_CHANGED).Payload, &adminChanged))
+	assert.Equal(t, "carol", adminChanged.Admin)
+	assert.Equal(t, []parser.LobbyPlayer{{Name: "bob", Connected: true}, {Name: "carol", IsAdmin: true, Connected: true}}, adminChanged.Lobby.Players)
+
+	repo.On("SetAdmin", "xxxxxx", "bob").Return(nil).Once()
+	carol.Close()
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_ADMIN_CHANGED).Payload, &adminChanged))
+	assert.Equal(t, "bob", adminChanged.Admin)
+}
+
+func TestAdminHandedOverWithNobodyConnected(t *testing.T) {
+	repo := dbMock.NewRepository(t)
+	repo.On("GetGameById", "xxxxxx").Return(&db.Game{GameId: "xxxxxx", CurrentRound: 1, TotalRounds: 1})
+	repo.On("GetGamePlayers", "xxxxxx").Return([]db.Player{
+		{Name: "alice", GameId: "xxxxxx", IsAdmin: true},
+		{Name: "bob", GameId: "xxxxxx"},
+		{Name: "carol", GameId: "xxxxxx"},
+	}, nil)
+	gs := InitGameState("xxxxxx", repo, words.NewStaticWordBank([]string{"apple"}), clock.New())
+	gs.reconnectGrace = time.Hour
+	admin := func() string {
+		var admin string
+		gs.call(func() { admin = gs.admin })
+		return admin
+	}
+	// bob and carol only joined over REST
+	connectPlayer(t, gs, "alice")
+
+	// bob joined first, he takes over although he isn't connected
+	repo.On("DeletePlayer", "xxxxxx", "alice").Return(nil).Once()
+	repo.On("SetAdmin", "xxxxxx", "bob").Return(nil).Once()
+	require.Nil(t, gs.RemoveConnection("alice", parser.LEFT_KICKED))
+	assert.Equal(t, "bob", admin())
+
+	// Whoever connects next takes over from an admin nobody could succeed
+	repo.On("DeletePlayer", "xxxxxx", "bob").Return(nil).Once()
+	repo.On("SetAdmin", "xxxxxx", "carol").Return(errors.New("database is locked")).Once()
+	require.Nil(t, gs.RemoveConnection("bob", parser.LEFT_KICKED))
+	assert.Equal(t, "bob", admin())
+	repo.On("SetAdmin", "xxxxxx", "carol").Return(nil).Once()
+	carol := connectPlayer(t, gs, "carol")
+	adminChanged := parser.AdminChangedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, carol, parser.MSG_ADMIN_CHANGED).Payload, &adminChanged))
+	assert.Equal(t, "carol", adminChanged.Admin)
+}
+
+func TestRemovedDrawerEndsTurn(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.wordChoiceDuration = 2 * time.Second
+	gs.turnDuration = 2 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	carol := connectPlayer(t, gs, "carol")
+	repo := gs.db.(*dbMock.Repository)
+	repo.On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	repo.On("DeletePlayer", "xxxxxx", "alice").Return(nil).Once()
+	require.Nil(t, gs.Start())
+
+	// alice leaves while choosing her word, her turn is skipped
+	expectEvent(t, alice, parser.MSG_WORD_CHOICES)
+	require.Nil(t, gs.RemoveConnection("alice", parser.LEFT_QUIT))
+	choosing := parser.ChoosingWordEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, carol, parser.MSG_CHOOSING_WORD).Payload, &choosing))
+	assert.Equal(t, "alice", choosing.Drawer)
+	require.Nil(t, json.Unmarshal(expectEvent(t, carol, parser.MSG_CHOOSING_WORD).Payload, &choosing))
+	assert.Equal(t, "bob", choosing.Drawer)
+	expectEvent(t, bob, parser.MSG_WORD_CHOICES)
+	gs.call(func() { assert.Equal(t, []string{"carol", "bob"}, gs.turnQueue) })
+}
+
+func TestDisconnectedPlayersEndTurn(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.wordChoiceDuration = 2 * time.Second
+	gs.turnDuration = 5 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	carol := connectPlayer(t, gs, "carol")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	// bob found alice's word, carol loses her connection before she does
+	choices := parser.WordChoicesEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_WORD_CHOICES).Payload, &choices))
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: choices.Words[0]})
+	expectEvent(t, bob, parser.MSG_TURN_START)
+	sendInput(t, bob, parser.MSG_CHAT, parser.ChatInput{Text: choices.Words[0]})
+	expectEvent(t, alice, parser.MSG_CORRECT_GUESS)
+	carol.Close()
+	turnEnd := parser.TurnEndEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_END).Payload, &turnEnd))
+	assert.Equal(t, "alice", turnEnd.Drawer)
+
+	// bob loses his connection while drawing
+	require.Nil(t, json.Unmarshal(expectEvent(t, bob, parser.MSG_WORD_CHOICES).Payload, &choices))
+	sendInput(t, bob, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: choices.Words[0]})
+	expectEvent(t, alice, parser.MSG_TURN_START)
+	bob.Close()
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_END).Payload, &turnEnd))
+	assert.Equal(t, "bob", turnEnd.Drawer)
+}
+
+func TestReconnectWithinGracePeriod(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.reconnectGrace = 2 * time.Second
+	defer gs.SetReconnectGrace(time.Hour)
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	connectPlayer(t, gs, "carol")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	bob.Close()
+	left := parser.PlayerLeftEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.LEFT_DISCONNECTED, left.Reason)
+	assert.True(t, isAbsent(gs, "bob"))
+
+	// bob is skipped as the drawer while he is away
+	require.Nil(t, gs.Start())
+	turn := parser.TurnStartEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_START).Payload, &turn))
+	assert.Equal(t, "alice", turn.Drawer)
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_TURN_START).Payload, &turn))
+	assert.Equal(t, "carol", turn.Drawer)
+
+	// and keeps his seat when he comes back
+	connectPlayer(t, gs, "bob")
+	assert.False(t, isAbsent(gs, "bob"))
+	assert.True(t, gs.HasPlayer("bob"))
+	gs.call(func() { assert.Contains(t, gs.turnQueue, "bob") })
+}
+
+func TestRemovedAfterGracePeriod(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.reconnectGrace = 50 * time.Millisecond
+	defer gs.SetReconnectGrace(time.Hour)
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	gs.db.(*dbMock.Repository).On("DeletePlayer", "xxxxxx", "bob").Return(nil).Once()
+
+	bob.Close()
+	left := parser.PlayerLeftEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.LEFT_DISCONNECTED, left.Reason)
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, parser.LEFT_TIMED_OUT, left.Reason)
+	assert.False(t, gs.HasPlayer("bob"))
+	assert.False(t, isAbsent(gs, "bob"))
+}
+
+func TestSessionsOfConnectedPlayersRefreshed(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	fake := clock.NewFake(time.Now())
+	gs.clock = fake
+	connectPlayer(t, gs, "alice")
+	refreshed := make(chan string, 2)
+	expiresAt := fake.Now().Add(SESSION_REFRESH_INTERVAL + time.Hour)
+	gs.db.(*dbMock.Repository).On("RefreshToken", "xxxxxx", mock.Anything, expiresAt).Run(func(args mock.Arguments) {
+		refreshed <- args.String(1)
+	}).Return(nil)
+	gs.SetSessionTTL(time.Hour)
+
+	fake.Advance(SESSION_REFRESH_INTERVAL)
+	select {
+	case player := <-refreshed:
+		assert.Equal(t, "alice", player, "Only connected players are refreshed")
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Session was not refreshed")
+	}
+	gs.Close()
+	assert.Empty(t, refreshed)
+	assert.Equal(t, 0, fake.Pending(), "A closed game must stop refreshing sessions")
+}
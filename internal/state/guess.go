@@ -0,0 +1,80 @@
+package state
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+type verdict int
+
+const (
+	WRONG verdict = iota
+	CLOSE
+	CORRECT
+)
+
+// normalizeGuess lower-cases text, strips diacritics, turns punctuation into
+// whitespace and collapses it so that "  Crème-brûlée!" and "creme brulee"
+// compare equal
+func normalizeGuess(text string) string {
+	stripMarks := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(stripMarks, text)
+	if err != nil {
+		stripped = text
+	}
+	stripped = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return r
+	}, stripped)
+	return strings.Join(strings.Fields(strings.ToLower(stripped)), " ")
+}
+
+// editDistance is the Levenshtein distance between a and b, counted in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i += 1 {
+		curr[0] = i
+		for j := 1; j <= len(rb); j += 1 {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// closeGuessDistance is how many edits away from the word a guess may be to count as close
+func closeGuessDistance(word string) int {
+	if len([]rune(word)) > 6 {
+		return 2
+	}
+	return 1
+}
+
+func evaluateGuess(guess, word string) verdict {
+	guess, word = normalizeGuess(guess), normalizeGuess(word)
+	if len(guess) == 0 || len(word) == 0 {
+		return WRONG
+	}
+	if guess == word {
+		return CORRECT
+	}
+	if editDistance(guess, word) <= closeGuessDistance(word) {
+		return CLOSE
+	}
+	return WRONG
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeGuess(t *testing.T) {
+	tests := []struct {
+		description string
+		guess       string
+		expected    string
+	}{
+		{"Test lower-casing", "BaNaNa", "banana"},
+		{"Test surrounding whitespace is trimmed", "  banana \t", "banana"},
+		{"Test inner whitespace is collapsed", "ice    cream", "ice cream"},
+		{"Test diacritics are stripped", "Crème Brûlée", "creme brulee"},
+		{"Test punctuation is stripped", "Apple!", "apple"},
+		{"Test punctuation between words", "ice-cream...", "ice cream"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, normalizeGuess(tc.guess))
+		})
+	}
+}
+
+func TestEvaluateGuess(t *testing.T) {
+	tests := []struct {
+		description string
+		guess       string
+		word        string
+		expected    verdict
+	}{
+		{"Test exact match", "penguin", "penguin", CORRECT},
+		{"Test match ignoring case and accents", " PÉNGUIN ", "penguin", CORRECT},
+		{"Test match ignoring punctuation", "Ice-cream!", "ice cream", CORRECT},
+		{"Test one typo in a short word", "robt", "robot", CLOSE},
+		{"Test two typos in a short word", "rbt", "robot", WRONG},
+		{"Test two typos in a long word", "lighthoose", "lighthouse", CLOSE},
+		{"Test unrelated word", "zebra", "penguin", WRONG},
+		{"Test empty guess", "   ", "penguin", WRONG},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, evaluateGuess(tc.guess, tc.word))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"math/rand"
+	"time"
+	"unicode"
+)
+
+const DEFAULT_HINT_COUNT = 2
+const MAX_HINT_COUNT = 5
+
+// HINT_MASK stands in for the letters guessers haven't been shown yet
+const HINT_MASK = '_'
+
+// hintSchedule spreads count reveals evenly over the turn, the last one
+// coming well before the deadline
+func hintSchedule(turnStart time.Time, turnDuration time.Duration, count uint8) []time.Time {
+	schedule := make([]time.Time, 0, count)
+	for i := 1; i <= int(count); i += 1 {
+		offset := turnDuration * time.Duration(i) / time.Duration(int(count)+1)
+		schedule = append(schedule, turnStart.Add(offset))
+	}
+	return schedule
+}
+
+// maskWord hides every letter of word that hasn't been revealed, anything
+// that isn't a letter, like spaces, is always shown
+func maskWord(word string, revealed map[int]bool) string {
+	masked := []rune(word)
+	for i, r := range masked {
+		if unicode.IsLetter(r) && !revealed[i] {
+			masked[i] = HINT_MASK
+		}
+	}
+	return string(masked)
+}
+
+// maxReveals caps the hints so that at least half of the word's letters stay hidden
+func maxReveals(word string) int {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			letters += 1
+		}
+	}
+	return letters / 2
+}
+
+// revealLetter picks a random hidden letter of word and marks it as revealed,
+// it returns false when there's nothing left to reveal
+func revealLetter(word string, revealed map[int]bool) bool {
+	if len(revealed) >= maxReveals(word) {
+		return false
+	}
+	hidden := []int{}
+	for i, r := range []rune(word) {
+		if unicode.IsLetter(r) && !revealed[i] {
+			hidden = append(hidden, i)
+		}
+	}
+	if len(hidden) == 0 {
+		return false
+	}
+	revealed[hidden[rand.Intn(len(hidden))]] = true
+	return true
+}
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHintSchedule(t *testing.T) {
+	start := time.Now()
+	schedule := hintSchedule(start, time.Minute, 2)
+	assert.Equal(t, []time.Time{start.Add(20 * time.Second), start.Add(40 * time.Second)}, schedule)
+	assert.Empty(t, hintSchedule(start, time.Minute, 0))
+}
+
+func TestMaskWord(t *testing.T) {
+	assert.Equal(t, "_____", maskWord("apple", nil))
+	assert.Equal(t, "a___e", maskWord("apple", map[int]bool{0: true, 4: true}))
+	assert.Equal(t, "___ _____-___", maskWord("ice cream-bar", nil))
+}
+
+func TestRevealLetter(t *testing.T) {
+	revealed := map[int]bool{}
+	// Half of "banana" may be revealed, the rest stays hidden
+	for i := 0; i < 3; i += 1 {
+		assert.True(t, revealLetter("banana", revealed))
+	}
+	assert.False(t, revealLetter("banana", revealed))
+	assert.Len(t, revealed, 3)
+	assert.False(t, revealLetter("a", map[int]bool{}))
+}
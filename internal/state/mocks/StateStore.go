@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	iter "iter"
+
 	state "github.com/anchal00/doodle/internal/state"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -20,6 +22,86 @@ func (_m *StateStore) EXPECT() *StateStore_Expecter {
 	return &StateStore_Expecter{mock: &_m.Mock}
 }
 
+// All provides a mock function with no fields
+func (_m *StateStore) All() iter.Seq2[string, *state.GameState] {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for All")
+	}
+
+	var r0 iter.Seq2[string, *state.GameState]
+	if rf, ok := ret.Get(0).(func() iter.Seq2[string, *state.GameState]); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(iter.Seq2[string, *state.GameState])
+		}
+	}
+
+	return r0
+}
+
+// StateStore_All_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'All'
+type StateStore_All_Call struct {
+	*mock.Call
+}
+
+// All is a helper method to define mock.On call
+func (_e *StateStore_Expecter) All() *StateStore_All_Call {
+	return &StateStore_All_Call{Call: _e.mock.On("All")}
+}
+
+func (_c *StateStore_All_Call) Run(run func()) *StateStore_All_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *StateStore_All_Call) Return(_a0 iter.Seq2[string, *state.GameState]) *StateStore_All_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *StateStore_All_Call) RunAndReturn(run func() iter.Seq2[string, *state.GameState]) *StateStore_All_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteGameState provides a mock function with given fields: gameId
+func (_m *StateStore) DeleteGameState(gameId string) {
+	_m.Called(gameId)
+}
+
+// StateStore_DeleteGameState_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteGameState'
+type StateStore_DeleteGameState_Call struct {
+	*mock.Call
+}
+
+// DeleteGameState is a helper method to define mock.On call
+//   - gameId string
+func (_e *StateStore_Expecter) DeleteGameState(gameId interface{}) *StateStore_DeleteGameState_Call {
+	return &StateStore_DeleteGameState_Call{Call: _e.mock.On("DeleteGameState", gameId)}
+}
+
+func (_c *StateStore_DeleteGameState_Call) Run(run func(gameId string)) *StateStore_DeleteGameState_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *StateStore_DeleteGameState_Call) Return() *StateStore_DeleteGameState_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *StateStore_DeleteGameState_Call) RunAndReturn(run func(string)) *StateStore_DeleteGameState_Call {
+	_c.Run(run)
+	return _c
+}
+
 // GetGameState provides a mock function with given fields: gameId
 func (_m *StateStore) GetGameState(gameId string) (*state.GameState, error) {
 	ret := _m.Called(gameId)
@@ -78,6 +160,53 @@ func (_c *StateStore_GetGameState_Call) RunAndReturn(run func(string) (*state.Ga
 	return _c
 }
 
+// List provides a mock function with no fields
+func (_m *StateStore) List() []string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// StateStore_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type StateStore_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+func (_e *StateStore_Expecter) List() *StateStore_List_Call {
+	return &StateStore_List_Call{Call: _e.mock.On("List")}
+}
+
+func (_c *StateStore_List_Call) Run(run func()) *StateStore_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *StateStore_List_Call) Return(_a0 []string) *StateStore_List_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *StateStore_List_Call) RunAndReturn(run func() []string) *StateStore_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetGameState provides a mock function with given fields: gameId, gs
 func (_m *StateStore) SetGameState(gameId string, gs *state.GameState) {
 	_m.Called(gameId, gs)
@@ -0,0 +1,71 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/logger"
+	"fmt"
+	"time"
+)
+
+// FINISHED_GAME_TTL is how long a finished game is kept around for a rematch
+const FINISHED_GAME_TTL = 10 * time.Minute
+
+// ABANDONED_GAME_TTL is how long a game nobody is connected to is kept around
+const ABANDONED_GAME_TTL = 30 * time.Minute
+const REAP_INTERVAL = time.Minute
+
+// Reaper evicts the games of a StateStore once they have expired, see
+// GameState.Expired, closing their connections and deleting them from the db
+type Reaper struct {
+	store        StateStore
+	db           db.Repository
+	clock        clock.Clock
+	finishedTTL  time.Duration
+	abandonedTTL time.Duration
+	log          logger.Logger
+}
+
+func NewReaper(store StateStore, database db.Repository, clk clock.Clock, finishedTTL, abandonedTTL time.Duration) *Reaper {
+	return &Reaper{
+		store:        store,
+		db:           database,
+		clock:        clk,
+		finishedTTL:  finishedTTL,
+		abandonedTTL: abandonedTTL,
+		log:          logger.New("reaper"),
+	}
+}
+
+// Reap evicts every game that has expired by now and returns their ids
+func (r *Reaper) Reap(now time.Time) []string {
+	reaped := []string{}
+	for gameId, gs := range r.store.All() {
+		if !gs.Expired(now, r.finishedTTL, r.abandonedTTL) {
+			continue
+		}
+		r.store.DeleteGameState(gameId)
+		gs.Close()
+		if err := r.db.DeleteGame(gameId); err != nil {
+			r.log.Error(fmt.Sprintf("Failed to delete game %s", gameId), err)
+		}
+		r.log.Info(fmt.Sprintf("Evicted game %s", gameId))
+		reaped = append(reaped, gameId)
+	}
+	return reaped
+}
+
+// Run reaps expired games every interval until stop is closed
+func (r *Reaper) Run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		tick := make(chan struct{})
+		timer := r.clock.AfterFunc(interval, func() { close(tick) })
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-tick:
+			r.Reap(r.clock.Now())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	dbMock "github.com/anchal00/doodle/internal/db/mocks"
+	"github.com/anchal00/doodle/internal/parser"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectClosed reads off conn until the server closes it
+func expectClosed(t *testing.T, conn *websocket.Conn) {
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "Connection should be closed by the server", err)
+			return
+		}
+	}
+}
+
+func TestReaperEvictsExpiredGames(t *testing.T) {
+	store := NewInMemoryGameStore()
+	repo := dbMock.NewRepository(t)
+	reaper := NewReaper(store, repo, clock.New(), time.Minute, time.Hour)
+
+	lobby := newTestGameState(t, 2, "carol")
+	store.SetGameState("lobby", lobby)
+	playing := newTestGameState(t, 2, "alice", "bob")
+	connectPlayer(t, playing, "alice")
+	connectPlayer(t, playing, "bob")
+	require.Nil(t, playing.Start())
+	store.SetGameState("playing", playing)
+	finished := newTestGameState(t, 0, "dave")
+	dave := connectPlayer(t, finished, "dave")
+	require.Nil(t, finished.Start())
+	expectEvent(t, dave, parser.MSG_GAME_OVER)
+	store.SetGameState("finished", finished)
+
+	now := time.Now()
+	assert.Empty(t, reaper.Reap(now), "Nothing has expired yet")
+
+	repo.On("DeleteGame", "finished").Return(nil).Once()
+	assert.Equal(t, []string{"finished"}, reaper.Reap(now.Add(2*time.Minute)))
+	expectClosed(t, dave)
+	assert.ElementsMatch(t, []string{"lobby", "playing"}, store.List())
+
+	repo.On("DeleteGame", "lobby").Return(nil).Once()
+	assert.Equal(t, []string{"lobby"}, reaper.Reap(now.Add(2*time.Hour)), "Games with players connected are kept")
+	assert.Equal(t, []string{"playing"}, store.List())
+}
+
+func TestAbandonedOnceLastPlayerLeaves(t *testing.T) {
+	gs := newTestGameState(t, 2, "alice")
+	alice := connectPlayer(t, gs, "alice")
+	assert.False(t, gs.Expired(time.Now().Add(time.Hour), time.Minute, time.Minute), "Connected games are not abandoned")
+
+	alice.Close()
+	assert.Eventually(t, func() bool { return isAbsent(gs, "alice") }, time.Second, 10*time.Millisecond)
+	leftAt := time.Now()
+	assert.False(t, gs.Expired(leftAt, time.Minute, time.Minute))
+	assert.True(t, gs.Expired(leftAt.Add(time.Minute), time.Minute, time.Minute))
+}
+
+func TestClosedGame(t *testing.T) {
+	gs := newTestGameState(t, 2, "alice", "bob")
+	alice := connectPlayer(t, gs, "alice")
+	gs.Close()
+	expectClosed(t, alice)
+
+	assert.Equal(t, ErrGameClosed, gs.Start())
+	assert.Equal(t, ErrGameClosed, gs.RemoveConnection("alice", parser.LEFT_KICKED))
+	assert.Equal(t, FINISHED, gs.GetState())
+	assert.True(t, gs.Expired(time.Now(), time.Hour, time.Hour))
+	// Late connections are turned away
+	bob := connectPlayer(t, gs, "bob")
+	require.Nil(t, bob.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err := bob.ReadMessage()
+	assert.NotNil(t, err)
+	// Closing twice is harmless
+	gs.Close()
+}
+
+func TestReaperRunsOnItsClock(t *testing.T) {
+	store := NewInMemoryGameStore()
+	repo := dbMock.NewRepository(t)
+	fake := clock.NewFake(time.Now())
+	reaper := NewReaper(store, repo, fake, time.Minute, time.Hour)
+	finished := newTestGameState(t, 0, "dave")
+	dave := connectPlayer(t, finished, "dave")
+	require.Nil(t, finished.Start())
+	expectEvent(t, dave, parser.MSG_GAME_OVER)
+	store.SetGameState("finished", finished)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		reaper.Run(time.Minute, stop)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return fake.Pending() == 1 }, time.Second, time.Millisecond)
+	fake.Advance(time.Minute)
+	assert.Equal(t, []string{"finished"}, store.List(), "Only a minute has passed since the game finished")
+
+	repo.On("DeleteGame", "finished").Return(nil).Once()
+	require.Eventually(t, func() bool { return fake.Pending() == 1 }, time.Second, time.Millisecond)
+	fake.Advance(2 * time.Minute)
+	require.Eventually(t, func() bool { return len(store.List()) == 0 }, time.Second, time.Millisecond)
+	expectClosed(t, dave)
+	close(stop)
+	<-done
+	assert.Zero(t, fake.Pending(), "The next tick is cancelled once the reaper stops")
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"cmp"
+	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/parser"
+	"slices"
+	"time"
+)
+
+var DEFAULT_SCORING_RULES = db.ScoringRules{
+	GuessMaxPoints: 100,
+	GuessMinPoints: 20,
+	DrawerPoints:   25,
+}
+
+// rankScores orders scores from best to worst, players on the same score share
+// a rank and the next rank is skipped, e.g. 1, 2, 2, 4
+func rankScores(scores []db.Score) []parser.Standing {
+	scores = slices.Clone(scores)
+	slices.SortStableFunc(scores, func(a, b db.Score) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(a.Player, b.Player)
+	})
+	standings := make([]parser.Standing, len(scores))
+	for i, score := range scores {
+		rank := i + 1
+		if i > 0 && score.Score == scores[i-1].Score {
+			rank = standings[i-1].Rank
+		}
+		standings[i] = parser.Standing{Rank: rank, Player: score.Player, Score: score.Score}
+	}
+	return standings
+}
+
+// guessPoints scales a guesser's reward linearly from GuessMaxPoints, when the
+// word is guessed right as the turn starts, down to GuessMinPoints at the deadline
+func guessPoints(rules db.ScoringRules, remaining, turnDuration time.Duration) uint {
+	maxPoints, minPoints := uint(rules.GuessMaxPoints), uint(rules.GuessMinPoints)
+	if minPoints >= maxPoints || turnDuration <= 0 {
+		return maxPoints
+	}
+	remaining = min(max(remaining, 0), turnDuration)
+	bonus := float64(maxPoints-minPoints) * float64(remaining) / float64(turnDuration)
+	return minPoints + uint(bonus)
+}
@@ -0,0 +1,41 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/parser"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuessPoints(t *testing.T) {
+	rules := db.ScoringRules{GuessMaxPoints: 100, GuessMinPoints: 20, DrawerPoints: 10}
+	tests := []struct {
+		description string
+		remaining   time.Duration
+		expected    uint
+	}{
+		{"Test guess as the turn starts", time.Minute, 100},
+		{"Test guess halfway through the turn", 30 * time.Second, 60},
+		{"Test guess at the deadline", 0, 20},
+		{"Test guess after the deadline", -time.Second, 20},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, guessPoints(rules, tc.remaining, time.Minute))
+		})
+	}
+}
+
+func TestRankScores(t *testing.T) {
+	scores := []db.Score{{Player: "dave", Score: 10}, {Player: "bob", Score: 50}, {Player: "carol", Score: 50}, {Player: "alice", Score: 80}}
+	expected := []parser.Standing{
+		{Rank: 1, Player: "alice", Score: 80},
+		{Rank: 2, Player: "bob", Score: 50},
+		{Rank: 2, Player: "carol", Score: 50},
+		{Rank: 4, Player: "dave", Score: 10},
+	}
+	assert.Equal(t, expected, rankScores(scores))
+	assert.Empty(t, rankScores([]db.Score{}))
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// SESSION_REFRESH_INTERVAL is how often the session tokens of connected
+// players are refreshed, so that players who stay on the game's websocket
+// for longer than their token lasts can still reconnect and call the api
+const SESSION_REFRESH_INTERVAL = 15 * time.Minute
+
+// SetSessionTTL has the game keep the session tokens of its connected players
+// valid for ttl from now on, refreshing them every SESSION_REFRESH_INTERVAL
+// or twice per ttl, whichever is more often
+func (g *GameState) SetSessionTTL(ttl time.Duration) {
+	_ = g.call(func() {
+		g.sessionTTL = ttl
+		// A timer already set picks up the new ttl when it fires
+		if g.sessionTimer == nil {
+			g.scheduleSessionRefresh()
+		}
+	})
+}
+
+func (g *GameState) scheduleSessionRefresh() {
+	g.sessionTimer = g.after(min(SESSION_REFRESH_INTERVAL, g.sessionTTL/2), timerEvent{kind: SESSION_TIMER})
+}
+
+// refreshSessions pushes back the expiry of every connected player's token
+func (g *GameState) refreshSessions() {
+	expiresAt := g.clock.Now().Add(g.sessionTTL)
+	for player := range g.connections {
+		if err := g.db.RefreshToken(g.gameId, player, expiresAt); err != nil {
+			g.log.Error(fmt.Sprintf("Failed to refresh session of player %s", player), err)
+		}
+	}
+	g.scheduleSessionRefresh()
+}
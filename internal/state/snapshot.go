@@ -0,0 +1,203 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/words"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-set/v3"
+)
+
+// Snapshot is what a game needs to pick up where it left off after a restart.
+// Its settings and players are read back from the games and players tables
+type Snapshot struct {
+	State        state     `json:"state"`
+	CurrentRound uint8     `json:"current_round"`
+	TurnQueue    []string  `json:"turn_queue"`
+	Drawn        []string  `json:"drawn"`
+	Drawer       string    `json:"drawer,omitempty"`
+	Candidates   []string  `json:"candidates,omitempty"`
+	ChooseBy     time.Time `json:"choose_by"`
+	Word         string    `json:"word,omitempty"`
+	Revealed     []int     `json:"revealed,omitempty"`
+	Guessed      []string  `json:"guessed,omitempty"`
+	TurnEndsAt   time.Time `json:"turn_ends_at"`
+	RoundEndsAt  time.Time `json:"round_ends_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	// Scores are running totals, RoundScores and TurnScores those not yet
+	// persisted to the scores table
+	Scores      map[string]uint `json:"scores"`
+	RoundScores map[string]uint `json:"round_scores"`
+	TurnScores  map[string]uint `json:"turn_scores"`
+}
+
+// EnableSnapshots has the game save a Snapshot at every turn transition
+func (g *GameState) EnableSnapshots() {
+	_ = g.call(func() { g.snapshots = true })
+}
+
+// SaveSnapshot saves the game as it is now
+func (g *GameState) SaveSnapshot() error {
+	var err error
+	if callErr := g.call(func() { err = g.saveSnapshot() }); callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+func (g *GameState) snapshot() Snapshot {
+	revealed := []int{}
+	for i := range g.revealed {
+		revealed = append(revealed, i)
+	}
+	slices.Sort(revealed)
+	return Snapshot{
+		State:        g.st,
+		CurrentRound: g.currentRound,
+		TurnQueue:    slices.Clone(g.turnQueue),
+		Drawn:        g.drawn.Slice(),
+		Drawer:       g.drawer,
+		Candidates:   slices.Clone(g.candidates),
+		ChooseBy:     g.chooseBy,
+		Word:         g.word,
+		Revealed:     revealed,
+		Guessed:      g.guessed.Slice(),
+		TurnEndsAt:   g.turnEndsAt,
+		RoundEndsAt:  g.roundEndsAt,
+		FinishedAt:   g.finishedAt,
+		Scores:       g.scores,
+		RoundScores:  g.roundScores,
+		TurnScores:   g.turnScores,
+	}
+}
+
+func (g *GameState) saveSnapshot() error {
+	data, err := json.Marshal(g.snapshot())
+	if err != nil {
+		return err
+	}
+	return g.db.SaveSnapshot(g.gameId, data, g.clock.Now())
+}
+
+// checkpoint saves a Snapshot at a turn transition, if the game keeps them
+func (g *GameState) checkpoint() {
+	if !g.snapshots {
+		return
+	}
+	if err := g.saveSnapshot(); err != nil {
+		g.log.Error("Failed to save snapshot", err)
+	}
+}
+
+// RestoreGameState rebuilds a game from the snapshot it last saved. Its timers
+// pick up where they were, those that ran out while the server was down fire
+// right away. Players get their seat back as they reconnect
+func RestoreGameState(gameId string, database db.Repository, wordBank words.WordBank, clk clock.Clock, data []byte) (*GameState, error) {
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("Failed to decode snapshot of game %s: %w", gameId, err)
+	}
+	gs := InitGameState(gameId, database, wordBank, clk)
+	if err := gs.call(func() { gs.restore(snapshot) }); err != nil {
+		return nil, err
+	}
+	return gs, nil
+}
+
+func (g *GameState) restore(snapshot Snapshot) {
+	g.st = snapshot.State
+	g.currentRound = snapshot.CurrentRound
+	// Players who left since keep out of the turn order, those who joined
+	// since take their turn last
+	turnQueue := slices.DeleteFunc(slices.Clone(snapshot.TurnQueue), func(p string) bool { return !g.players.Contains(p) })
+	for _, player := range g.turnQueue {
+		if !slices.Contains(turnQueue, player) {
+			turnQueue = append(turnQueue, player)
+		}
+	}
+	g.turnQueue = turnQueue
+	g.drawn = set.Set[string]{}
+	g.drawn.InsertSlice(snapshot.Drawn)
+	g.drawer = snapshot.Drawer
+	g.candidates = snapshot.Candidates
+	g.chooseBy = snapshot.ChooseBy
+	g.word = snapshot.Word
+	g.revealed = make(map[int]bool)
+	for _, i := range snapshot.Revealed {
+		g.revealed[i] = true
+	}
+	g.guessed = set.Set[string]{}
+	g.guessed.InsertSlice(snapshot.Guessed)
+	g.turnEndsAt = snapshot.TurnEndsAt
+	g.roundEndsAt = snapshot.RoundEndsAt
+	g.finishedAt = snapshot.FinishedAt
+	g.scores = copyScores(snapshot.Scores)
+	g.roundScores = copyScores(snapshot.RoundScores)
+	g.turnScores = copyScores(snapshot.TurnScores)
+	g.log.Info(fmt.Sprintf("Restored game in round %d of %d", g.currentRound, g.maxRounds))
+	// Nobody is connected after a restart, players keep their seat for as
+	// long as those who lose their connection do
+	for player := range g.players.Items() {
+		g.awaitReconnect(player)
+	}
+	g.holdUntilReconnected()
+}
+
+// holdUntilReconnected keeps a restored game from starting turns or rounds
+// before its players had the time to come back. Its timers are set again as
+// soon as every player is back, or once reconnectGrace has passed
+func (g *GameState) holdUntilReconnected() {
+	g.nextPhase()
+	g.held = true
+	g.schedule(g.reconnectGrace, RESUME_TIMER)
+}
+
+// resumeOnceReconnected resumes a held game as the last player it waits for
+// comes back or leaves
+func (g *GameState) resumeOnceReconnected() {
+	if g.held && len(g.absent) == 0 {
+		g.resume()
+	}
+}
+
+func (g *GameState) resume() {
+	if !g.held {
+		return
+	}
+	g.log.Info("Resuming restored game")
+	g.resumeTimers()
+}
+
+func copyScores(scores map[string]uint) map[string]uint {
+	copied := make(map[string]uint, len(scores))
+	for player, points := range scores {
+		copied[player] = points
+	}
+	return copied
+}
+
+// resumeTimers sets the timers of the phase the game was restored in
+func (g *GameState) resumeTimers() {
+	g.nextPhase()
+	switch {
+	case g.st == ROUND_END:
+		g.schedule(g.roundEndsAt.Sub(g.clock.Now()), ROUND_END_TIMER)
+	case g.st != STARTED:
+		// Nothing runs in the lobby or once the game is over
+	case g.candidates != nil:
+		g.schedule(g.chooseBy.Sub(g.clock.Now()), WORD_CHOICE_TIMER)
+	case len(g.word) != 0:
+		g.schedule(g.turnEndsAt.Sub(g.clock.Now()), TURN_TIMER)
+		hints := hintSchedule(g.turnEndsAt.Add(-g.turnDuration), g.turnDuration, g.hintCount)
+		for _, at := range hints[min(len(g.revealed), len(hints)):] {
+			g.schedule(at.Sub(g.clock.Now()), HINT_TIMER)
+		}
+	default:
+		// The snapshot was saved between two turns
+		g.nextTurn()
+	}
+}
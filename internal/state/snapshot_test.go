@@ -0,0 +1,196 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	dbMock "github.com/anchal00/doodle/internal/db/mocks"
+	"github.com/anchal00/doodle/internal/parser"
+	"github.com/anchal00/doodle/internal/words"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// expectSnapshot waits for the next snapshot the game saves
+func expectSnapshot(t *testing.T, saved chan Snapshot) Snapshot {
+	select {
+	case snapshot := <-saved:
+		return snapshot
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "No snapshot saved")
+		return Snapshot{}
+	}
+}
+
+func TestSnapshotsSavedAtTurnTransitions(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob")
+	gs.wordChoiceDuration = 2 * time.Second
+	saved := make(chan Snapshot, 16)
+	gs.db.(*dbMock.Repository).On("SaveSnapshot", "xxxxxx", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		snapshot := Snapshot{}
+		require.Nil(t, json.Unmarshal(args.Get(1).([]byte), &snapshot))
+		saved <- snapshot
+	}).Return(nil)
+	gs.EnableSnapshots()
+	alice := connectPlayer(t, gs, "alice")
+	connectPlayer(t, gs, "bob")
+	require.Nil(t, gs.Start())
+
+	snapshot := expectSnapshot(t, saved)
+	assert.Equal(t, STARTED, snapshot.State)
+	assert.Equal(t, "alice", snapshot.Drawer)
+	assert.Len(t, snapshot.Candidates, WORD_CHOICE_COUNT)
+	assert.Equal(t, []string{"bob", "alice"}, snapshot.TurnQueue)
+
+	sendInput(t, alice, parser.MSG_CHOOSE_WORD, parser.ChooseWordInput{Word: snapshot.Candidates[0]})
+	drawing := expectSnapshot(t, saved)
+	assert.Equal(t, snapshot.Candidates[0], drawing.Word)
+	assert.Empty(t, drawing.Candidates)
+	assert.False(t, drawing.TurnEndsAt.IsZero())
+
+	// bob's word is picked for him without waiting out the whole word choice
+	gs.call(func() { gs.wordChoiceDuration = 20 * time.Millisecond })
+	for snapshot.State != FINISHED {
+		snapshot = expectSnapshot(t, saved)
+	}
+	assert.False(t, snapshot.FinishedAt.IsZero())
+}
+
+func TestRestoreTurnInProgress(t *testing.T) {
+	original := newTestGameState(t, 2, "alice", "bob")
+	original.wordChoiceDuration = 2 * time.Second
+	original.turnDuration = TURN_DURATION
+	connectPlayer(t, original, "alice")
+	connectPlayer(t, original, "bob")
+	require.Nil(t, original.Start())
+	var data []byte
+	original.call(func() {
+		require.Nil(t, original.chooseWord("alice", parser.ChooseWordInput{Word: original.candidates[0]}))
+		original.guessed.Insert("bob")
+		original.turnScores["bob"] = 80
+		var err error
+		data, err = json.Marshal(original.snapshot())
+		require.Nil(t, err)
+	})
+	original.Close()
+
+	// carol joined while the server was down
+	restored, err := RestoreGameState("xxxxxx", newTestRepo(t, 2, "alice", "bob", "carol"), words.NewStaticWordBank([]string{"apple"}), clock.New(), data)
+	require.Nil(t, err)
+	t.Cleanup(restored.Close)
+	assert.Equal(t, STARTED, restored.GetState())
+	assert.Equal(t, "alice", restored.GetDrawer())
+	restored.call(func() {
+		assert.Equal(t, original.word, restored.word)
+		assert.True(t, restored.guessed.Contains("bob"))
+		assert.Equal(t, original.turnScores, restored.turnScores)
+		assert.Equal(t, []string{"bob", "alice", "carol"}, restored.turnQueue)
+		assert.True(t, restored.drawn.Contains("alice"))
+	})
+
+	// The drawer picks up their turn as they reconnect
+	alice := connectPlayer(t, restored, "alice")
+	canvas := parser.CanvasSnapshotEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_CANVAS_SNAPSHOT).Payload, &canvas))
+	assert.Equal(t, "alice", canvas.Drawer)
+	assert.Equal(t, original.word, canvas.Word)
+	require.NotNil(t, canvas.EndsAt)
+	assert.WithinDuration(t, original.turnEndsAt, *canvas.EndsAt, time.Millisecond)
+}
+
+func TestRestoreResumesExpiredTimers(t *testing.T) {
+	snapshot := Snapshot{
+		State:        ROUND_END,
+		CurrentRound: 1,
+		TurnQueue:    []string{"alice", "bob"},
+		Drawn:        []string{"alice", "bob"},
+		RoundEndsAt:  time.Now().Add(-time.Minute),
+		Scores:       map[string]uint{"alice": 50},
+	}
+	data, err := json.Marshal(snapshot)
+	require.Nil(t, err)
+	restored, err := RestoreGameState("xxxxxx", newTestRepo(t, 2, "alice", "bob"), words.NewStaticWordBank([]string{"apple"}), clock.New(), data)
+	require.Nil(t, err)
+	t.Cleanup(restored.Close)
+
+	// The next round waits for the players to come back
+	connectPlayer(t, restored, "alice")
+	restored.call(func() {
+		assert.Equal(t, ROUND_END, restored.st)
+		assert.Equal(t, uint8(1), restored.currentRound)
+	})
+
+	// The scoreboard was up for long enough while the server was down
+	connectPlayer(t, restored, "bob")
+	assert.Eventually(t, func() bool { return restored.GetDrawer() == "alice" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, STARTED, restored.GetState())
+	restored.call(func() {
+		assert.Equal(t, uint8(2), restored.currentRound)
+		assert.Equal(t, uint(50), restored.scores["alice"])
+	})
+}
+
+func TestSnapshotStoreRestore(t *testing.T) {
+	snapshot, err := json.Marshal(Snapshot{State: CREATED, CurrentRound: 1, TurnQueue: []string{"alice"}})
+	require.Nil(t, err)
+	repo := newTestRepo(t, 2, "alice")
+	repo.On("GetSnapshots").Return([]db.GameSnapshot{
+		{GameId: "xxxxxx", Snapshot: snapshot},
+		{GameId: "deleted", Snapshot: snapshot},
+		{GameId: "corrupt", Snapshot: []byte("{")},
+	}, nil)
+	repo.On("GetGameById", "deleted").Return(nil)
+	repo.On("GetGameById", "corrupt").Return(&db.Game{GameId: "corrupt"})
+	store := NewSnapshotStore(repo, words.NewStaticWordBank([]string{"apple"}), clock.New())
+	require.Nil(t, store.Restore())
+
+	assert.Equal(t, []string{"xxxxxx"}, store.List(), "Games that can't be restored are skipped")
+	gs, err := store.GetGameState("xxxxxx")
+	require.Nil(t, err)
+	t.Cleanup(gs.Close)
+	assert.True(t, gs.HasPlayer("alice"))
+
+	repo.On("SaveSnapshot", "xxxxxx", mock.Anything, mock.Anything).Return(nil).Once()
+	store.SaveAll()
+}
+
+func TestRestoredPlayersAwaitReconnect(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	snapshot := Snapshot{
+		State:        ROUND_END,
+		CurrentRound: 1,
+		TurnQueue:    []string{"alice", "bob", "carol"},
+		Drawn:        []string{"alice", "bob", "carol"},
+		RoundEndsAt:  fake.Now().Add(-time.Minute),
+	}
+	data, err := json.Marshal(snapshot)
+	require.Nil(t, err)
+	repo := newTestRepo(t, 2, "alice", "bob", "carol")
+	restored, err := RestoreGameState("xxxxxx", repo, words.NewStaticWordBank([]string{"apple"}), fake, data)
+	require.Nil(t, err)
+	t.Cleanup(restored.Close)
+	restored.SetReconnectGrace(time.Minute)
+	restored.call(func() {
+		for _, player := range []string{"alice", "bob", "carol"} {
+			assert.True(t, restored.isAbsent(player), "Nobody is connected after a restart")
+		}
+	})
+
+	connectPlayer(t, restored, "alice")
+	connectPlayer(t, restored, "carol")
+	fake.Advance(time.Minute - time.Second)
+	assert.Equal(t, ROUND_END, restored.GetState(), "The game waits for bob")
+
+	// The game goes on without bob once he had the time to come back
+	repo.On("DeletePlayer", "xxxxxx", "bob").Return(nil).Once()
+	fake.Advance(time.Second)
+	assert.Eventually(t, func() bool { return !restored.HasPlayer("bob") }, time.Second, 10*time.Millisecond, "Bob did not come back in time")
+	assert.True(t, restored.HasPlayer("alice"))
+	assert.True(t, restored.HasPlayer("carol"))
+	assert.Eventually(t, func() bool { return restored.GetDrawer() == "alice" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, STARTED, restored.GetState())
+}
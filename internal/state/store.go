@@ -2,23 +2,42 @@
 package state
 
 import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/db"
+	"github.com/anchal00/doodle/internal/logger"
+	"github.com/anchal00/doodle/internal/words"
+	"errors"
 	"fmt"
+	"iter"
+	"sync"
+	"time"
 )
 
 type StateStore interface {
 	GetGameState(gameId string) (*GameState, error)
 	SetGameState(gameId string, gs *GameState)
+	// DeleteGameState forgets the game, it is up to the caller to close it
+	DeleteGameState(gameId string)
+	// List returns the ids of every game in the store
+	List() []string
+	// All iterates over a snapshot of the store, games added or deleted while
+	// iterating may or may not show up
+	All() iter.Seq2[string, *GameState]
 }
 
+// InMemoryGameStateStore is shared by every HTTP handler, mut guards store
 type InMemoryGameStateStore struct {
 	store map[string]*GameState
+	mut   *sync.RWMutex
 }
 
 func NewInMemoryGameStore() *InMemoryGameStateStore {
-	return &InMemoryGameStateStore{store: make(map[string]*GameState)}
+	return &InMemoryGameStateStore{store: make(map[string]*GameState), mut: &sync.RWMutex{}}
 }
 
 func (i InMemoryGameStateStore) GetGameState(gameId string) (*GameState, error) {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
 	state, exists := i.store[gameId]
 	if !exists {
 		return nil, fmt.Errorf("No state found for this game Id %s", gameId)
@@ -27,5 +46,120 @@ func (i InMemoryGameStateStore) GetGameState(gameId string) (*GameState, error)
 }
 
 func (i InMemoryGameStateStore) SetGameState(gameId string, state *GameState) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
 	i.store[gameId] = state
 }
+
+func (i InMemoryGameStateStore) DeleteGameState(gameId string) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	delete(i.store, gameId)
+}
+
+func (i InMemoryGameStateStore) List() []string {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
+	gameIds := make([]string, 0, len(i.store))
+	for gameId := range i.store {
+		gameIds = append(gameIds, gameId)
+	}
+	return gameIds
+}
+
+func (i InMemoryGameStateStore) All() iter.Seq2[string, *GameState] {
+	i.mut.RLock()
+	snapshot := make(map[string]*GameState, len(i.store))
+	for gameId, state := range i.store {
+		snapshot[gameId] = state
+	}
+	i.mut.RUnlock()
+	return func(yield func(string, *GameState) bool) {
+		for gameId, state := range snapshot {
+			if !yield(gameId, state) {
+				return
+			}
+		}
+	}
+}
+
+// SNAPSHOT_INTERVAL is how often a SnapshotStore saves every game, on top of
+// the snapshot each game saves at its turn transitions
+const SNAPSHOT_INTERVAL = 10 * time.Second
+
+// SnapshotStore is an InMemoryGameStateStore whose games save a Snapshot to
+// the database as they go, so that they survive a restart, see Restore
+type SnapshotStore struct {
+	*InMemoryGameStateStore
+	db    db.Repository
+	words words.WordBank
+	clock clock.Clock
+	log   logger.Logger
+}
+
+func NewSnapshotStore(database db.Repository, wordBank words.WordBank, clk clock.Clock) *SnapshotStore {
+	return &SnapshotStore{
+		InMemoryGameStateStore: NewInMemoryGameStore(),
+		db:                     database,
+		words:                  wordBank,
+		clock:                  clk,
+		log:                    logger.New("snapshots"),
+	}
+}
+
+func (s *SnapshotStore) SetGameState(gameId string, gs *GameState) {
+	gs.EnableSnapshots()
+	s.InMemoryGameStateStore.SetGameState(gameId, gs)
+	if err := gs.SaveSnapshot(); err != nil {
+		s.log.Error(fmt.Sprintf("Failed to save snapshot of game %s", gameId), err)
+	}
+}
+
+// Restore rebuilds every game that has a snapshot, games whose snapshot can't
+// be read are skipped
+func (s *SnapshotStore) Restore() error {
+	snapshots, err := s.db.GetSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if s.db.GetGameById(snapshot.GameId) == nil {
+			s.log.Error("Failed to restore game", fmt.Errorf("Game %s not found", snapshot.GameId))
+			continue
+		}
+		gs, err := RestoreGameState(snapshot.GameId, s.db, s.words, s.clock, snapshot.Snapshot)
+		if err != nil {
+			s.log.Error(fmt.Sprintf("Failed to restore game %s", snapshot.GameId), err)
+			continue
+		}
+		gs.EnableSnapshots()
+		s.InMemoryGameStateStore.SetGameState(snapshot.GameId, gs)
+	}
+	s.log.Info(fmt.Sprintf("Restored %d games", len(s.List())))
+	return nil
+}
+
+// SaveAll saves a snapshot of every game
+func (s *SnapshotStore) SaveAll() {
+	for gameId, gs := range s.All() {
+		// Games closed since are being evicted
+		if err := gs.SaveSnapshot(); err != nil && !errors.Is(err, ErrGameClosed) {
+			s.log.Error(fmt.Sprintf("Failed to save snapshot of game %s", gameId), err)
+		}
+	}
+}
+
+// Run saves every game each interval until stop is closed
+func (s *SnapshotStore) Run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		tick := make(chan struct{})
+		timer := s.clock.AfterFunc(interval, func() { close(tick) })
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-tick:
+			s.SaveAll()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSetGetDelete(t *testing.T) {
+	store := NewInMemoryGameStore()
+	first, second := &GameState{}, &GameState{}
+	store.SetGameState("first", first)
+	store.SetGameState("second", second)
+
+	gs, err := store.GetGameState("first")
+	require.Nil(t, err)
+	assert.Same(t, first, gs)
+	assert.ElementsMatch(t, []string{"first", "second"}, store.List())
+
+	store.DeleteGameState("first")
+	_, err = store.GetGameState("first")
+	assert.NotNil(t, err, "Deleted game should be gone")
+	assert.Equal(t, []string{"second"}, store.List())
+	// Deleting twice is harmless
+	store.DeleteGameState("first")
+}
+
+func TestStoreIterationIsASnapshot(t *testing.T) {
+	store := NewInMemoryGameStore()
+	for i := 0; i < 3; i += 1 {
+		store.SetGameState(fmt.Sprintf("game%d", i), &GameState{})
+	}
+	seen := []string{}
+	for gameId := range store.All() {
+		// Games may be evicted while iterating without deadlocking
+		store.DeleteGameState(gameId)
+		seen = append(seen, gameId)
+	}
+	assert.ElementsMatch(t, []string{"game0", "game1", "game2"}, seen)
+	assert.Empty(t, store.List())
+
+	store.SetGameState("game0", &GameState{})
+	store.SetGameState("game1", &GameState{})
+	count := 0
+	for range store.All() {
+		count += 1
+		break
+	}
+	assert.Equal(t, 1, count, "Iteration should stop when asked to")
+}
+
+func TestStoreConcurrentAccess(t *testing.T) {
+	store := NewInMemoryGameStore()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i += 1 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gameId := fmt.Sprintf("game%d", i)
+			for j := 0; j < 100; j += 1 {
+				store.SetGameState(gameId, &GameState{})
+				_, _ = store.GetGameState(gameId)
+				_ = store.List()
+				for range store.All() {
+				}
+				if j%2 == 0 {
+					store.DeleteGameState(gameId)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, store.List(), 20)
+}
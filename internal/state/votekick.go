@@ -0,0 +1,87 @@
+package state
+
+import (
+	"github.com/anchal00/doodle/internal/clock"
+	"github.com/anchal00/doodle/internal/parser"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-set/v3"
+)
+
+// VOTE_KICK_DURATION is how long a vote to kick a player stays open
+const VOTE_KICK_DURATION = 30 * time.Second
+
+// VOTE_KICK_COOLDOWN is how long a player has to wait before starting another vote
+const VOTE_KICK_COOLDOWN = 60 * time.Second
+
+type kickVote struct {
+	voters set.Set[string]
+	endsAt time.Time
+	timer  clock.Timer
+}
+
+// votesNeeded is a majority of the connected players, the target included even
+// though they don't get a say
+func (g *GameState) votesNeeded() int {
+	return len(g.connections)/2 + 1
+}
+
+// voteKick starts a vote to kick the input's target or adds player's vote to
+// the one already running. The target is removed as soon as the vote passes
+func (g *GameState) voteKick(player string, input parser.VoteKickInput) error {
+	if g.st != STARTED && g.st != ROUND_END {
+		return fmt.Errorf("Game %s is not in progress, only the admin can kick players", g.gameId)
+	}
+	target := input.Target
+	if target == player {
+		return fmt.Errorf("Player %s can't vote to kick themselves", player)
+	}
+	if !g.players.Contains(target) {
+		return fmt.Errorf("Player %s is not part of the game", target)
+	}
+	vote, running := g.kickVotes[target]
+	if !running {
+		if startedAt, voted := g.kickVotesStarted[player]; voted && g.clock.Now().Sub(startedAt) < g.voteKickCooldown {
+			return fmt.Errorf("Player %s has to wait before starting another vote", player)
+		}
+		vote = &kickVote{voters: set.Set[string]{}, endsAt: g.clock.Now().Add(g.voteKickDuration)}
+		g.kickVotes[target] = vote
+		g.kickVotesStarted[player] = g.clock.Now()
+		vote.timer = g.after(g.voteKickDuration, timerEvent{kind: KICK_VOTE_TIMER, player: target, vote: vote})
+		g.log.Info(fmt.Sprintf("Player %s started a vote to kick %s", player, target))
+	}
+	if vote.voters.Contains(player) {
+		return fmt.Errorf("Player %s has already voted to kick %s", player, target)
+	}
+	vote.voters.Insert(player)
+	voters := vote.voters.Slice()
+	slices.Sort(voters)
+	needed := g.votesNeeded()
+	g.fanOut("", parser.MSG_KICK_VOTE, parser.KickVoteEvent{Target: target, Voters: voters, Needed: needed, EndsAt: vote.endsAt})
+	if len(voters) < needed {
+		return nil
+	}
+	g.dropKickVote(target)
+	g.fanOut("", parser.MSG_KICK_VOTE_ENDED, parser.KickVoteEndedEvent{Target: target, Kicked: true})
+	g.log.Info(fmt.Sprintf("Players voted to kick %s", target))
+	return g.removePlayer(target, parser.LEFT_KICKED)
+}
+
+// expireKickVote closes vote against target if it is still running once its time is up
+func (g *GameState) expireKickVote(target string, vote *kickVote) {
+	if g.kickVotes[target] != vote {
+		return
+	}
+	delete(g.kickVotes, target)
+	g.fanOut("", parser.MSG_KICK_VOTE_ENDED, parser.KickVoteEndedEvent{Target: target, Kicked: false})
+}
+
+// dropKickVote ends the vote running against target, if any, and stops its timer
+func (g *GameState) dropKickVote(target string) {
+	if vote, running := g.kickVotes[target]; running {
+		vote.timer.Stop()
+		delete(g.kickVotes, target)
+	}
+}
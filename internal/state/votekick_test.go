@@ -0,0 +1,84 @@
+package state
+
+import (
+	dbMock "github.com/anchal00/doodle/internal/db/mocks"
+	"github.com/anchal00/doodle/internal/parser"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteKick(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol", "dave")
+	gs.wordChoiceDuration = 5 * time.Second
+	alice := connectPlayer(t, gs, "alice")
+	bob := connectPlayer(t, gs, "bob")
+	carol := connectPlayer(t, gs, "carol")
+	dave := connectPlayer(t, gs, "dave")
+	repo := gs.db.(*dbMock.Repository)
+	repo.On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	// The admin takes care of the lobby
+	sendInput(t, bob, parser.MSG_VOTE_KICK, parser.VoteKickInput{Target: "dave"})
+	expectEvent(t, bob, parser.MSG_ERROR)
+	require.Nil(t, gs.Start())
+
+	sendInput(t, bob, parser.MSG_VOTE_KICK, parser.VoteKickInput{Target: "dave"})
+	vote := parser.KickVoteEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, dave, parser.MSG_KICK_VOTE).Payload, &vote))
+	assert.Equal(t, "dave", vote.Target)
+	assert.Equal(t, []string{"bob"}, vote.Voters)
+	assert.Equal(t, 3, vote.Needed)
+	expectEvent(t, alice, parser.MSG_KICK_VOTE)
+
+	rejected := []parser.VoteKickInput{
+		{Target: "dave"},   // bob has already voted
+		{Target: "carol"},  // bob has to wait before starting another vote
+		{Target: "bob"},    // nobody votes against themselves
+		{Target: "nobody"}, // not a player
+	}
+	for _, input := range rejected {
+		sendInput(t, bob, parser.MSG_VOTE_KICK, input)
+		expectEvent(t, bob, parser.MSG_ERROR)
+	}
+
+	sendInput(t, carol, parser.MSG_VOTE_KICK, parser.VoteKickInput{Target: "dave"})
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_KICK_VOTE).Payload, &vote))
+	assert.Equal(t, []string{"bob", "carol"}, vote.Voters)
+
+	var running *kickVote
+	gs.call(func() { running = gs.kickVotes["dave"] })
+	repo.On("DeletePlayer", "xxxxxx", "dave").Return(nil).Once()
+	sendInput(t, alice, parser.MSG_VOTE_KICK, parser.VoteKickInput{Target: "dave"})
+	ended := parser.KickVoteEndedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_KICK_VOTE_ENDED).Payload, &ended))
+	assert.Equal(t, parser.KickVoteEndedEvent{Target: "dave", Kicked: true}, ended)
+	left := parser.PlayerLeftEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_PLAYER_LEFT).Payload, &left))
+	assert.Equal(t, "dave", left.Player)
+	assert.Equal(t, parser.LEFT_KICKED, left.Reason)
+	assert.False(t, gs.HasPlayer("dave"))
+	gs.call(func() { assert.False(t, running.timer.Stop(), "The timer of a vote that passed must be stopped") })
+}
+
+func TestKickVoteExpires(t *testing.T) {
+	gs := newTestGameState(t, 1, "alice", "bob", "carol")
+	gs.wordChoiceDuration = 5 * time.Second
+	gs.voteKickDuration = 50 * time.Millisecond
+	alice := connectPlayer(t, gs, "alice")
+	connectPlayer(t, gs, "bob")
+	connectPlayer(t, gs, "carol")
+	gs.db.(*dbMock.Repository).On("UpdatePlayerScore", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	require.Nil(t, gs.Start())
+
+	sendInput(t, alice, parser.MSG_VOTE_KICK, parser.VoteKickInput{Target: "carol"})
+	expectEvent(t, alice, parser.MSG_KICK_VOTE)
+	ended := parser.KickVoteEndedEvent{}
+	require.Nil(t, json.Unmarshal(expectEvent(t, alice, parser.MSG_KICK_VOTE_ENDED).Payload, &ended))
+	assert.Equal(t, parser.KickVoteEndedEvent{Target: "carol", Kicked: false}, ended)
+	assert.True(t, gs.HasPlayer("carol"))
+}
@@ -0,0 +1,92 @@
+//go:generate mockery --with-expecter=true --name=WordBank --output=./mocks
+package words
+
+import (
+	"bufio"
+	"github.com/anchal00/doodle/internal/db"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// DEFAULT_WORDS seed the word bank so that a fresh server can host games
+// before an admin loads a list of their own
+var DEFAULT_WORDS = []string{
+	"apple", "banana", "bicycle", "bridge", "butterfly", "camera", "candle",
+	"castle", "cloud", "dragon", "elephant", "giraffe", "guitar", "hammer",
+	"helicopter", "island", "kangaroo", "ladder", "lighthouse", "mountain",
+	"octopus", "penguin", "pizza", "rainbow", "robot", "rocket", "scissors",
+	"snowman", "spider", "sunflower", "telescope", "tornado", "train",
+	"umbrella", "volcano", "waterfall", "windmill", "zebra",
+}
+
+type WordBank interface {
+	// Candidates returns up to count distinct words for a drawer to pick from
+	Candidates(count uint8) ([]string, error)
+}
+
+type RepositoryWordBank struct {
+	db db.Repository
+}
+
+func NewRepositoryWordBank(repository db.Repository) WordBank {
+	return &RepositoryWordBank{db: repository}
+}
+
+func (r *RepositoryWordBank) Candidates(count uint8) ([]string, error) {
+	words, err := r.db.GetRandomWords(count)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("Word bank is empty")
+	}
+	return words, nil
+}
+
+type StaticWordBank struct {
+	words []string
+}
+
+func NewStaticWordBank(words []string) WordBank {
+	return &StaticWordBank{words: words}
+}
+
+func (s *StaticWordBank) Candidates(count uint8) ([]string, error) {
+	if len(s.words) == 0 {
+		return nil, fmt.Errorf("Word bank is empty")
+	}
+	picked := make([]string, 0, count)
+	for _, i := range rand.Perm(len(s.words)) {
+		if len(picked) == int(count) {
+			break
+		}
+		picked = append(picked, s.words[i])
+	}
+	return picked, nil
+}
+
+// LoadWordList adds every word listed in the file at path to the repository's
+// word bank. The file holds one word per line, blank lines and lines starting
+// with '#' are ignored
+func LoadWordList(repository db.Repository, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	words := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if len(word) == 0 || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return repository.AddWords(words)
+}
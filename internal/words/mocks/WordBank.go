@@ -0,0 +1,90 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// WordBank is an autogenerated mock type for the WordBank type
+type WordBank struct {
+	mock.Mock
+}
+
+type WordBank_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *WordBank) EXPECT() *WordBank_Expecter {
+	return &WordBank_Expecter{mock: &_m.Mock}
+}
+
+// Candidates provides a mock function with given fields: count
+func (_m *WordBank) Candidates(count uint8) ([]string, error) {
+	ret := _m.Called(count)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Candidates")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uint8) ([]string, error)); ok {
+		return rf(count)
+	}
+	if rf, ok := ret.Get(0).(func(uint8) []string); ok {
+		r0 = rf(count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint8) error); ok {
+		r1 = rf(count)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WordBank_Candidates_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Candidates'
+type WordBank_Candidates_Call struct {
+	*mock.Call
+}
+
+// Candidates is a helper method to define mock.On call
+//   - count uint8
+func (_e *WordBank_Expecter) Candidates(count interface{}) *WordBank_Candidates_Call {
+	return &WordBank_Candidates_Call{Call: _e.mock.On("Candidates", count)}
+}
+
+func (_c *WordBank_Candidates_Call) Run(run func(count uint8)) *WordBank_Candidates_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint8))
+	})
+	return _c
+}
+
+func (_c *WordBank_Candidates_Call) Return(_a0 []string, _a1 error) *WordBank_Candidates_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *WordBank_Candidates_Call) RunAndReturn(run func(uint8) ([]string, error)) *WordBank_Candidates_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewWordBank creates a new instance of WordBank. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewWordBank(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *WordBank {
+	mock := &WordBank{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}